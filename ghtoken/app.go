@@ -0,0 +1,69 @@
+package ghtoken
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v55/github"
+)
+
+// MaxJWTExpiry is the longest lifetime GitHub accepts for an app JWT
+const MaxJWTExpiry = 10 * time.Minute
+
+// AppCredentials authenticates as a GitHub App with its ID and private key
+type AppCredentials struct {
+	// AppID is the ID, or the client ID, of the app
+	AppID string
+	// Signer signs with the private key generated for the app. It is either
+	// the *rsa.PrivateKey returned by ParsePrivateKey, or a signer keeping
+	// the key out of reach of the process.
+	Signer crypto.Signer
+	// Client calls the GitHub API. Defaults to a client for github.com.
+	Client *Client
+}
+
+// NewAppCredentials returns the credentials of an app on github.com
+func NewAppCredentials(appID string, signer crypto.Signer) *AppCredentials {
+	return &AppCredentials{AppID: appID, Signer: signer}
+}
+
+// JWT signs a JSON Web Token authenticating as the app. An expiry outside of
+// (0, MaxJWTExpiry] is replaced by MaxJWTExpiry. The token is issued a minute
+// in the past to allow for clock drift.
+func (a *AppCredentials) JWT(expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > MaxJWTExpiry {
+		expiry = MaxJWTExpiry
+	}
+
+	if a.Signer == nil {
+		return "", fmt.Errorf("unable to sign JWT: no signer configured")
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(signerRS256, jwt.MapClaims{
+		"iat": jwt.NewNumericDate(now.Add(-60 * time.Second)),
+		"exp": jwt.NewNumericDate(now.Add(expiry)),
+		"iss": a.AppID,
+	})
+	signedToken, err := token.SignedString(a.Signer)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign JWT: %w", err)
+	}
+
+	return signedToken, nil
+}
+
+// InstallationToken creates a new installation access token. The token is
+// restricted to the repositories and permissions of options when it is not
+// nil.
+func (a *AppCredentials) InstallationToken(ctx context.Context, installationID int64, options *github.InstallationTokenOptions) (*github.InstallationToken, error) {
+	jsonWebToken, err := a.JWT(MaxJWTExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed generating JWT: %w", err)
+	}
+
+	return a.Client.CreateInstallationToken(ctx, jsonWebToken, installationID, options)
+}
@@ -0,0 +1,165 @@
+package ghtoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAppCredentials returns credentials with a freshly generated key
+func newTestAppCredentials(t *testing.T) *AppCredentials {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	return NewAppCredentials("123456", key)
+}
+
+func TestJWT(t *testing.T) {
+	app := newTestAppCredentials(t)
+
+	tests := []struct {
+		name           string
+		expiry         time.Duration
+		expectedExpiry time.Duration
+	}{
+		{
+			name:           "within_limit",
+			expiry:         5 * time.Minute,
+			expectedExpiry: 5 * time.Minute,
+		},
+		{
+			name:           "above_limit",
+			expiry:         time.Hour,
+			expectedExpiry: MaxJWTExpiry,
+		},
+		{
+			name:           "zero",
+			expectedExpiry: MaxJWTExpiry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			signed, err := app.JWT(tt.expiry)
+			assert.NoError(t, err)
+
+			claims := jwt.RegisteredClaims{}
+			_, err = jwt.ParseWithClaims(signed, &claims, func(token *jwt.Token) (interface{}, error) {
+				return app.Signer.Public(), nil
+			}, jwt.WithValidMethods([]string{"RS256"}))
+			assert.NoError(t, err)
+
+			assert.Equal(t, "123456", claims.Issuer)
+			assert.WithinDuration(t, now.Add(-60*time.Second), claims.IssuedAt.Time, 2*time.Second)
+			assert.WithinDuration(t, now.Add(tt.expectedExpiry), claims.ExpiresAt.Time, 2*time.Second)
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, err := ParsePrivateKey([]byte("not a key"))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse key from PEM to RSA format")
+}
+
+func TestInstallationToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	app := newTestAppCredentials(t)
+	app.Client = &Client{BaseURL: "https://github.example.com/api/v3"}
+	options := &github.InstallationTokenOptions{Repositories: []string{"repo"}}
+
+	httpmock.RegisterResponder("POST", "https://github.example.com/api/v3/app/installations/12345/access_tokens",
+		func(req *http.Request) (*http.Response, error) {
+			assert.Contains(t, req.Header.Get("Authorization"), "Bearer ")
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+			body, _ := io.ReadAll(req.Body)
+			var received github.InstallationTokenOptions
+			assert.NoError(t, json.Unmarshal(body, &received))
+			assert.Equal(t, []string{"repo"}, received.Repositories)
+
+			return httpmock.NewJsonResponse(201, &github.InstallationToken{Token: github.String("ghs_test_token_123")})
+		})
+
+	token, err := app.InstallationToken(context.Background(), 12345, options)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_test_token_123", token.GetToken())
+}
+
+// opaqueSigner hides the private key behind crypto.Signer, like a key held
+// by an agent or a hardware token
+type opaqueSigner struct {
+	key *rsa.PrivateKey
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestJWTSigner(t *testing.T) {
+	rsaKey := newTestAppCredentials(t).Signer.(*rsa.PrivateKey)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		signer        crypto.Signer
+		expectedError string
+	}{
+		{
+			name:   "opaque_rsa_signer",
+			signer: opaqueSigner{key: rsaKey},
+		},
+		{
+			name:          "ecdsa_signer",
+			signer:        ecKey,
+			expectedError: "app keys must be RSA keys",
+		},
+		{
+			name:          "no_signer",
+			expectedError: "no signer configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := NewAppCredentials("123456", tt.signer).JWT(MaxJWTExpiry)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			_, err = jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+				return &rsaKey.PublicKey, nil
+			}, jwt.WithValidMethods([]string{"RS256"}))
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Package ghtoken generates JSON Web Tokens and installation access tokens
+// for GitHub Apps. It is the library the gh-token CLI is built on.
+//
+// Most programs only need AppCredentials, whose TokenSource and Transport
+// methods hand out installation tokens that are refreshed before they
+// expire:
+//
+//	key, err := ghtoken.ParsePrivateKey(pemBytes)
+//	if err != nil {
+//		return err
+//	}
+//	app := ghtoken.NewAppCredentials("1122334", key)
+//	client := &http.Client{Transport: app.Transport(ctx, 5566778, nil, nil)}
+package ghtoken
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+)
+
+// DefaultBaseURL is the REST API endpoint of github.com
+const DefaultBaseURL = "https://api.github.com"
+
+// ErrInstallationNotFound is returned when the app is not installed on the
+// requested account or repository
+var ErrInstallationNotFound = errors.New("installation not found")
+
+// StatusError is returned when the API answers with an unexpected status
+// code, e.g. 401 when it rejects the JWT of the app
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Client calls the REST API endpoints used to manage installation tokens.
+// The zero value talks to github.com with a default HTTP client.
+type Client struct {
+	// BaseURL is the REST API endpoint without a trailing slash, e.g.
+	// https://github.example.com/api/v3 for GitHub Enterprise Server.
+	// Defaults to DefaultBaseURL.
+	BaseURL string
+	// HTTPClient sends the requests. Defaults to a client using
+	// http.DefaultTransport.
+	HTTPClient *http.Client
+}
+
+// CreateInstallationToken creates an installation access token, authenticated
+// with a JWT of the app. The token is restricted to the repositories and
+// permissions of options when it is not nil.
+func (c *Client) CreateInstallationToken(ctx context.Context, jwt string, installationID int64, options *github.InstallationTokenOptions) (*github.InstallationToken, error) {
+	endpoint := c.endpoint(fmt.Sprintf("app/installations/%d/access_tokens", installationID))
+
+	var body io.Reader
+	if options != nil {
+		payload, err := json.Marshal(options)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal request body: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := c.newRequest(ctx, "POST", endpoint, jwt, body)
+	if err != nil {
+		return nil, err
+	}
+	if options != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to POST to %s: %w", endpoint, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != 201 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var response *github.InstallationToken
+	err = decodeResponse(resp, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ListInstallations lists all the installations of the app, authenticated
+// with a JWT of the app
+func (c *Client) ListInstallations(ctx context.Context, jwt string) ([]github.Installation, error) {
+	page := 1
+	var responses []github.Installation
+	for {
+		endpoint := c.endpoint(fmt.Sprintf("app/installations?per_page=100&page=%d", page))
+		req, err := c.newRequest(ctx, "GET", endpoint, jwt, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to GET %s: %w", endpoint, err)
+		}
+
+		var response []github.Installation
+		if resp.StatusCode != 200 {
+			err = &StatusError{StatusCode: resp.StatusCode}
+		} else {
+			err = decodeResponse(resp, &response)
+		}
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response...)
+
+		if len(response) < 100 {
+			break
+		}
+		page++
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
+	}
+
+	return responses, nil
+}
+
+// App returns the app authenticated by a JWT, which verifies that the key
+// signing it belongs to the app
+func (c *Client) App(ctx context.Context, jwt string) (*github.App, error) {
+	endpoint := c.endpoint("app")
+	req, err := c.newRequest(ctx, "GET", endpoint, jwt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to GET %s: %w", endpoint, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var response github.App
+	err = decodeResponse(resp, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// OrganizationInstallation returns the installation of the app on an
+// organization, or ErrInstallationNotFound
+func (c *Client) OrganizationInstallation(ctx context.Context, jwt, org string) (*github.Installation, error) {
+	return c.installation(ctx, jwt, fmt.Sprintf("orgs/%s/installation", url.PathEscape(org)))
+}
+
+// UserInstallation returns the installation of the app on a user account, or
+// ErrInstallationNotFound
+func (c *Client) UserInstallation(ctx context.Context, jwt, user string) (*github.Installation, error) {
+	return c.installation(ctx, jwt, fmt.Sprintf("users/%s/installation", url.PathEscape(user)))
+}
+
+// RepositoryInstallation returns the installation of the app on a
+// repository, or ErrInstallationNotFound
+func (c *Client) RepositoryInstallation(ctx context.Context, jwt, owner, repo string) (*github.Installation, error) {
+	return c.installation(ctx, jwt, fmt.Sprintf("repos/%s/%s/installation", url.PathEscape(owner), url.PathEscape(repo)))
+}
+
+func (c *Client) installation(ctx context.Context, jwt, path string) (*github.Installation, error) {
+	endpoint := c.endpoint(path)
+	req, err := c.newRequest(ctx, "GET", endpoint, jwt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to GET %s: %w", endpoint, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == 404 {
+		return nil, ErrInstallationNotFound
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var response github.Installation
+	err = decodeResponse(resp, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.ID == nil {
+		return nil, fmt.Errorf("response body does not contain an installation ID")
+	}
+
+	return &response, nil
+}
+
+// RevokeInstallationToken revokes an installation access token, which
+// authenticates its own revocation
+func (c *Client) RevokeInstallationToken(ctx context.Context, token string) error {
+	endpoint := c.endpoint("installation/token")
+	req, err := c.newRequest(ctx, "DELETE", endpoint, token, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to DELETE to %s: %w", endpoint, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != 204 {
+		return fmt.Errorf("token might be invalid or not properly formatted. Unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) endpoint(path string) string {
+	baseURL := DefaultBaseURL
+	if c != nil && c.BaseURL != "" {
+		baseURL = c.BaseURL
+	}
+
+	return baseURL + "/" + path
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c != nil && c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	return &http.Client{}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, endpoint, bearer string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s request to %s: %w", method, endpoint, err)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", bearer))
+	req.Header.Add("Accept", "application/vnd.github+json")
+	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Add("User-Agent", "Link-/gh-token")
+
+	return req, nil
+}
+
+func decodeResponse(resp *http.Response, value interface{}) error {
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	err = json.Unmarshal(bytes, value)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal response body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package ghtoken
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallationLookup(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	client := &Client{}
+
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		expectedID    int64
+		expectedError string
+	}{
+		{
+			name:       "found",
+			status:     200,
+			body:       `{"id": 12345}`,
+			expectedID: 12345,
+		},
+		{
+			name:          "not_found",
+			status:        404,
+			body:          `{"message": "Not Found"}`,
+			expectedError: ErrInstallationNotFound.Error(),
+		},
+		{
+			name:          "missing_id",
+			status:        200,
+			body:          `{}`,
+			expectedError: "does not contain an installation ID",
+		},
+		{
+			name:          "unexpected_status",
+			status:        500,
+			expectedError: "unexpected status code: 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			httpmock.RegisterResponder("GET", "https://api.github.com/repos/owner/repo/installation",
+				httpmock.NewStringResponder(tt.status, tt.body))
+
+			installation, err := client.RepositoryInstallation(context.Background(), "jwt", "owner", "repo")
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedID, installation.GetID())
+			}
+		})
+	}
+}
+
+func TestRevokeInstallationToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("DELETE", "https://api.github.com/installation/token",
+		httpmock.NewStringResponder(204, ""))
+
+	err := (&Client{}).RevokeInstallationToken(context.Background(), "ghs_test_token_123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,259 @@
+package ghtoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// pemArmor matches a PEM block whose line breaks may have been lost
+var pemArmor = regexp.MustCompile(`(?s)-----BEGIN ([A-Z0-9 ]+)-----(.*?)-----END [A-Z0-9 ]+-----`)
+
+// ParsePrivateKey parses a PEM encoded RSA private key, as downloaded from
+// the settings of the app. Keys mangled by secret stores are repaired like
+// RepairPrivateKey does.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	return ParsePrivateKeyWithPassphrase(data, nil)
+}
+
+// ParsePrivateKeyWithPassphrase parses a PEM encoded RSA private key like
+// ParsePrivateKey, decrypting it with the passphrase returned by passphrase
+// when it is encrypted. The passphrase is only asked for then, and
+// ErrPassphraseRequired is returned when passphrase is nil.
+func ParsePrivateKeyWithPassphrase(data []byte, passphrase func() ([]byte, error)) (*rsa.PrivateKey, error) {
+	key, _, err := RepairPrivateKey(data, passphrase)
+	return key, err
+}
+
+// RepairPrivateKey parses an RSA private key mangled by a secret store: with
+// escaped or CRLF line endings, surrounding quotes, lost line breaks or a
+// missing armor, or with its body in URL-safe or unpadded base64. It returns
+// the repairs which were needed, and errors naming the kind of key found
+// when it is not an RSA private key. Encrypted keys are decrypted like
+// ParsePrivateKeyWithPassphrase does.
+func RepairPrivateKey(data []byte, passphrase func() ([]byte, error)) (*rsa.PrivateKey, []string, error) {
+	var repairs []string
+	text := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+
+	if len(text) > 1 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		text = strings.TrimSpace(text[1 : len(text)-1])
+		repairs = append(repairs, "removed surrounding quotes")
+	}
+
+	if strings.Contains(text, `\n`) {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, `\r\n`, "\n"), `\n`, "\n")
+		repairs = append(repairs, `replaced escaped \n sequences with line breaks`)
+	}
+
+	if strings.Contains(text, "\r\n") {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		repairs = append(repairs, "converted CRLF line endings")
+	}
+
+	if block, _ := pem.Decode([]byte(text)); block != nil {
+		key, err := parseKeyBlock(block, passphrase)
+		return key, repairs, err
+	}
+
+	blockType, body := "", text
+	if match := pemArmor.FindStringSubmatch(text); match != nil {
+		blockType, body = match[1], match[2]
+		repairs = append(repairs, "restored the line breaks of the PEM body")
+	} else if strings.Contains(text, "-----BEGIN") {
+		return nil, repairs, fmt.Errorf("unable to parse key: the PEM armor is truncated, check that the END line was copied")
+	} else {
+		repairs = append(repairs, "added the missing PEM armor")
+	}
+
+	der, encoding, err := decodeKeyBody(body)
+	if err != nil {
+		return nil, repairs, fmt.Errorf("unable to parse key: it is neither PEM nor base64 encoded")
+	}
+	if encoding != "" {
+		repairs = append(repairs, "decoded "+encoding+" base64")
+	}
+
+	key, err := parseKeyBlock(&pem.Block{Type: blockType, Bytes: der}, passphrase)
+	return key, repairs, err
+}
+
+// RepairBase64PrivateKey decodes a base64 encoded private key, also
+// accepting the URL-safe and unpadded variants secret stores may produce,
+// and repairs the decoded key like RepairPrivateKey
+func RepairBase64PrivateKey(value string, passphrase func() ([]byte, error)) (*rsa.PrivateKey, []string, error) {
+	data, encoding, err := decodeKeyBody(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decode key from base64: %w", err)
+	}
+
+	key, repairs, err := RepairPrivateKey(data, passphrase)
+	if encoding != "" {
+		repairs = append([]string{"decoded the " + encoding + " base64 encoded key"}, repairs...)
+	}
+
+	return key, repairs, err
+}
+
+// decodeKeyBody decodes a PEM body or a base64 encoded key, ignoring
+// whitespace, and names the non-standard base64 variant it was encoded with
+func decodeKeyBody(body string) ([]byte, string, error) {
+	body = strings.Join(strings.Fields(body), "")
+
+	encodings := []struct {
+		name     string
+		encoding *base64.Encoding
+	}{
+		{"", base64.StdEncoding},
+		{"unpadded", base64.RawStdEncoding},
+		{"URL-safe", base64.URLEncoding},
+		{"unpadded URL-safe", base64.RawURLEncoding},
+	}
+
+	var err error
+	for _, e := range encodings {
+		var der []byte
+		der, err = e.encoding.DecodeString(body)
+		if err == nil {
+			return der, e.name, nil
+		}
+	}
+
+	return nil, "", err
+}
+
+// parseKeyBlock parses a PEM block, or the DER bytes of any key type when
+// the armor was missing
+func parseKeyBlock(block *pem.Block, passphrase func() ([]byte, error)) (*rsa.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY", "PRIVATE KEY", "":
+	case "ENCRYPTED PRIVATE KEY":
+		return decryptPKCS8Key(block.Bytes, passphrase)
+	case "EC PRIVATE KEY":
+		return nil, errECKey
+	case "PUBLIC KEY", "RSA PUBLIC KEY":
+		return nil, errPublicKey
+	case "CERTIFICATE":
+		return nil, fmt.Errorf("unable to parse key: this is a certificate, GitHub Apps need the private key downloaded from the app settings")
+	case "OPENSSH PRIVATE KEY":
+		return nil, fmt.Errorf("unable to parse key: this is an OpenSSH key, GitHub App keys are PEM encoded RSA private keys")
+	default:
+		return nil, fmt.Errorf("unable to parse key: unexpected PEM block %q, expected an RSA PRIVATE KEY", block.Type)
+	}
+
+	der := block.Bytes
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		var err error
+		der, err = decryptLegacyKey(block, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return rsaPrivateKey(key)
+	}
+
+	if _, err := x509.ParseECPrivateKey(der); err == nil {
+		return nil, errECKey
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return nil, errPublicKey
+	}
+	if _, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return nil, errPublicKey
+	}
+
+	return nil, fmt.Errorf("unable to parse key from PEM to RSA format: the key data is corrupted")
+}
+
+// rsaPrivateKey names the kind of a parsed key which is not an RSA key
+func rsaPrivateKey(key interface{}) (*rsa.PrivateKey, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return nil, errECKey
+	case ed25519.PrivateKey:
+		return nil, fmt.Errorf("unable to parse key: this is an Ed25519 key, GitHub Apps require RSA keys")
+	default:
+		return nil, fmt.Errorf("unable to parse key: this is a %T key, GitHub Apps require RSA keys", key)
+	}
+}
+
+// decryptLegacyKey decrypts a PEM block encrypted by OpenSSL with a
+// Proc-Type header, as written by openssl rsa -aes256
+func decryptLegacyKey(block *pem.Block, passphrase func() ([]byte, error)) ([]byte, error) {
+	password, err := requirePassphrase(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:staticcheck // legacy encryption is insecure, but still what openssl rsa -aes256 writes
+	der, err := x509.DecryptPEMBlock(block, password)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	return der, nil
+}
+
+// decryptPKCS8Key decrypts an encrypted PKCS #8 key, as written by
+// openssl pkcs8 -topk8
+func decryptPKCS8Key(der []byte, passphrase func() ([]byte, error)) (*rsa.PrivateKey, error) {
+	password, err := requirePassphrase(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := pkcs8.ParsePKCS8PrivateKey(der, password)
+	if err != nil {
+		if strings.Contains(err.Error(), "incorrect password") {
+			return nil, ErrIncorrectPassphrase
+		}
+		return nil, fmt.Errorf("unable to decrypt key: %w", err)
+	}
+
+	return rsaPrivateKey(key)
+}
+
+func requirePassphrase(passphrase func() ([]byte, error)) ([]byte, error) {
+	if passphrase == nil {
+		return nil, ErrPassphraseRequired
+	}
+
+	password, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if len(password) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	return password, nil
+}
+
+var (
+	// ErrPassphraseRequired is returned when the private key is encrypted
+	// and no passphrase was provided
+	ErrPassphraseRequired = errors.New("unable to parse key: the private key is encrypted and no passphrase was provided")
+	// ErrIncorrectPassphrase is returned when the passphrase does not
+	// decrypt the private key
+	ErrIncorrectPassphrase = errors.New("unable to decrypt key: incorrect passphrase")
+
+	errECKey     = errors.New("unable to parse key: this is an EC key, GitHub Apps require RSA keys")
+	errPublicKey = errors.New("unable to parse key: this looks like a public key, GitHub Apps need the private key downloaded from the app settings")
+)
@@ -0,0 +1,266 @@
+package ghtoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youmark/pkcs8"
+)
+
+// newTestKey returns a freshly generated key and its PKCS #8 PEM encoding, as
+// downloaded from the settings of an app
+func newTestKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestRepairPrivateKey(t *testing.T) {
+	key, keyBytes := newTestKey(t)
+	keyPEM := string(keyBytes)
+	block, _ := pem.Decode(keyBytes)
+
+	pkcs1 := x509.MarshalPKCS1PrivateKey(key)
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	assert.NoError(t, err)
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		data            string
+		expectedRepairs []string
+		expectedError   string
+	}{
+		{
+			name: "pkcs8",
+			data: keyPEM,
+		},
+		{
+			name: "pkcs1",
+			data: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1})),
+		},
+		{
+			name:            "escaped_newlines",
+			data:            strings.ReplaceAll(keyPEM, "\n", `\n`),
+			expectedRepairs: []string{`replaced escaped \n sequences with line breaks`},
+		},
+		{
+			name:            "crlf",
+			data:            strings.ReplaceAll(keyPEM, "\n", "\r\n"),
+			expectedRepairs: []string{"converted CRLF line endings"},
+		},
+		{
+			name:            "quoted",
+			data:            `"` + keyPEM + `"`,
+			expectedRepairs: []string{"removed surrounding quotes"},
+		},
+		{
+			name:            "lost_line_breaks",
+			data:            strings.ReplaceAll(keyPEM, "\n", " "),
+			expectedRepairs: []string{"restored the line breaks of the PEM body"},
+		},
+		{
+			name:            "missing_armor",
+			data:            base64.StdEncoding.EncodeToString(block.Bytes),
+			expectedRepairs: []string{"added the missing PEM armor"},
+		},
+		{
+			name:            "unpadded_url_safe_base64",
+			data:            base64.RawURLEncoding.EncodeToString(block.Bytes),
+			expectedRepairs: []string{"added the missing PEM armor", "decoded unpadded URL-safe base64"},
+		},
+		{
+			name:          "public_key",
+			data:          string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+			expectedError: "this looks like a public key",
+		},
+		{
+			name:          "public_key_without_armor",
+			data:          base64.StdEncoding.EncodeToString(publicKey),
+			expectedError: "this looks like a public key",
+		},
+		{
+			name:          "ec_key",
+			data:          string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})),
+			expectedError: "this is an EC key, GitHub Apps require RSA keys",
+		},
+		{
+			name:          "ec_key_pkcs8",
+			data:          string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8})),
+			expectedError: "this is an EC key, GitHub Apps require RSA keys",
+		},
+		{
+			name:          "truncated",
+			data:          keyPEM[:len(keyPEM)/2],
+			expectedError: "the PEM armor is truncated",
+		},
+		{
+			name:          "not_a_key",
+			data:          "hunter2!",
+			expectedError: "neither PEM nor base64 encoded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, repairs, err := RepairPrivateKey([]byte(tt.data), nil)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, key.Equal(parsed))
+			assert.Equal(t, tt.expectedRepairs, repairs)
+		})
+	}
+}
+
+func TestParsePrivateKeyWithPassphrase(t *testing.T) {
+	key, _ := newTestKey(t)
+
+	pkcs8DER, err := pkcs8.MarshalPrivateKey(key, []byte("correct horse"), nil)
+	assert.NoError(t, err)
+	//nolint:staticcheck // legacy encryption is what openssl rsa -aes256 writes
+	legacyBlock, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("correct horse"), x509.PEMCipherAES256)
+	assert.NoError(t, err)
+
+	encrypted := map[string][]byte{
+		"pkcs8":  pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8DER}),
+		"legacy": pem.EncodeToMemory(legacyBlock),
+	}
+
+	tests := []struct {
+		name          string
+		passphrase    func() ([]byte, error)
+		expectedError string
+	}{
+		{
+			name:       "correct_passphrase",
+			passphrase: func() ([]byte, error) { return []byte("correct horse"), nil },
+		},
+		{
+			name:          "incorrect_passphrase",
+			passphrase:    func() ([]byte, error) { return []byte("battery staple"), nil },
+			expectedError: "unable to",
+		},
+		{
+			name:          "no_passphrase",
+			expectedError: "the private key is encrypted",
+		},
+	}
+
+	for format, data := range encrypted {
+		for _, tt := range tests {
+			t.Run(format+"_"+tt.name, func(t *testing.T) {
+				parsed, err := ParsePrivateKeyWithPassphrase(data, tt.passphrase)
+
+				if tt.expectedError != "" {
+					assert.Error(t, err)
+					assert.Contains(t, err.Error(), tt.expectedError)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.True(t, key.Equal(parsed))
+			})
+		}
+	}
+}
+
+func TestRepairBase64PrivateKey(t *testing.T) {
+	key, keyPEM := newTestKey(t)
+
+	// A byte order mark encodes to 77u/ so the URL-safe encodings hold a _,
+	// and trailing line breaks make the length not a multiple of 3 so the
+	// unpadded encodings differ from the padded ones
+	keyPEM = append([]byte("\ufeff"), keyPEM...)
+	for len(keyPEM)%3 == 0 {
+		keyPEM = append(keyPEM, '\n')
+	}
+	assert.Contains(t, base64.URLEncoding.EncodeToString(keyPEM), "_")
+
+	tests := []struct {
+		name            string
+		value           string
+		expectedRepairs []string
+		expectedError   string
+	}{
+		{
+			name:  "standard",
+			value: base64.StdEncoding.EncodeToString(keyPEM),
+		},
+		{
+			name:            "unpadded",
+			value:           base64.RawStdEncoding.EncodeToString(keyPEM),
+			expectedRepairs: []string{"decoded the unpadded base64 encoded key"},
+		},
+		{
+			name:            "url_safe",
+			value:           base64.URLEncoding.EncodeToString(keyPEM),
+			expectedRepairs: []string{"decoded the URL-safe base64 encoded key"},
+		},
+		{
+			name:            "unpadded_url_safe",
+			value:           base64.RawURLEncoding.EncodeToString(keyPEM),
+			expectedRepairs: []string{"decoded the unpadded URL-safe base64 encoded key"},
+		},
+		{
+			name:  "wrapped",
+			value: wrapBase64(base64.StdEncoding.EncodeToString(keyPEM)),
+		},
+		{
+			name:          "not_base64",
+			value:         "hunter2!",
+			expectedError: "unable to decode key from base64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, repairs, err := RepairBase64PrivateKey(tt.value, nil)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, key.Equal(parsed))
+			assert.Equal(t, tt.expectedRepairs, repairs)
+		})
+	}
+}
+
+// wrapBase64 breaks base64 data into lines of 76 characters, as written by
+// the base64 command
+func wrapBase64(value string) string {
+	var lines []string
+	for len(value) > 76 {
+		lines = append(lines, value[:76])
+		value = value[76:]
+	}
+
+	return strings.Join(append(lines, value), "\n")
+}
@@ -0,0 +1,63 @@
+package ghtoken
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MessageSigner is implemented by signers which hash the message themselves,
+// such as an SSH agent, and thus cannot sign a precomputed digest. A
+// MessageSigner is handed the JWT signing input instead of its digest.
+type MessageSigner interface {
+	crypto.Signer
+	SignMessage(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// signingMethodRS256 signs RS256 tokens with a crypto.Signer, so that keys
+// held by an agent or a hardware token never have to be loaded in memory
+type signingMethodRS256 struct{}
+
+var signerRS256 = &signingMethodRS256{}
+
+func (m *signingMethodRS256) Alg() string {
+	return jwt.SigningMethodRS256.Alg()
+}
+
+func (m *signingMethodRS256) Sign(signingString string, key interface{}) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key of type %T is not a crypto.Signer", key)
+	}
+
+	if err := checkRSASigner(signer); err != nil {
+		return nil, err
+	}
+
+	if messageSigner, ok := signer.(MessageSigner); ok {
+		return messageSigner.SignMessage(rand.Reader, []byte(signingString), crypto.SHA256)
+	}
+
+	hasher := crypto.SHA256.New()
+	hasher.Write([]byte(signingString))
+
+	return signer.Sign(rand.Reader, hasher.Sum(nil), crypto.SHA256)
+}
+
+func (m *signingMethodRS256) Verify(signingString string, sig []byte, key interface{}) error {
+	return jwt.SigningMethodRS256.Verify(signingString, sig, key)
+}
+
+// checkRSASigner ensures the signer holds an RSA key, the only kind of key
+// GitHub issues for apps
+func checkRSASigner(signer crypto.Signer) error {
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return fmt.Errorf("app keys must be RSA keys, got a %T public key", signer.Public())
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package ghtoken
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"golang.org/x/oauth2"
+)
+
+// DefaultRefreshMargin is how long before their expiry the tokens of a
+// TokenSource are replaced, installation tokens being valid for an hour
+const DefaultRefreshMargin = 5 * time.Minute
+
+// TokenSource returns an oauth2.TokenSource handing out installation access
+// tokens. A token is reused until it is within DefaultRefreshMargin of its
+// expiry, and is then replaced by a new one. The context is used for the
+// token requests.
+func (a *AppCredentials) TokenSource(ctx context.Context, installationID int64, options *github.InstallationTokenOptions) oauth2.TokenSource {
+	return oauth2.ReuseTokenSourceWithExpiry(nil, &installationTokenSource{
+		ctx:            ctx,
+		app:            a,
+		installationID: installationID,
+		options:        options,
+	}, DefaultRefreshMargin)
+}
+
+// Transport returns an http.RoundTripper authenticating the requests sent
+// through base as the installation, with tokens from TokenSource. A nil
+// base uses http.DefaultTransport.
+func (a *AppCredentials) Transport(ctx context.Context, installationID int64, options *github.InstallationTokenOptions, base http.RoundTripper) http.RoundTripper {
+	return &oauth2.Transport{
+		Source: a.TokenSource(ctx, installationID, options),
+		Base:   base,
+	}
+}
+
+// installationTokenSource creates a new installation token on every call
+type installationTokenSource struct {
+	ctx            context.Context
+	app            *AppCredentials
+	installationID int64
+	options        *github.InstallationTokenOptions
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.app.InstallationToken(s.ctx, s.installationID, s.options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
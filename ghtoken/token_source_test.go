@@ -0,0 +1,86 @@
+package ghtoken
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// registerTokenResponder answers token requests with tokens expiring after
+// lifetime
+func registerTokenResponder(lifetime time.Duration) {
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(201, &github.InstallationToken{
+				Token:     github.String("ghs_test_token_123"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(lifetime)},
+			})
+		})
+}
+
+func TestTokenSource(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	app := newTestAppCredentials(t)
+
+	tests := []struct {
+		name          string
+		lifetime      time.Duration
+		expectedCalls int
+	}{
+		{
+			name:          "reuses_valid_token",
+			lifetime:      time.Hour,
+			expectedCalls: 1,
+		},
+		{
+			name:          "refreshes_expiring_token",
+			lifetime:      DefaultRefreshMargin - time.Minute,
+			expectedCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			registerTokenResponder(tt.lifetime)
+
+			source := app.TokenSource(context.Background(), 12345, nil)
+			for i := 0; i < 3; i++ {
+				token, err := source.Token()
+				assert.NoError(t, err)
+				assert.Equal(t, "ghs_test_token_123", token.AccessToken)
+				assert.Equal(t, "Bearer", token.TokenType)
+			}
+
+			assert.Equal(t, tt.expectedCalls, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	registerTokenResponder(time.Hour)
+	httpmock.RegisterResponder("GET", "https://api.github.com/repos/owner/repo",
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "Bearer ghs_test_token_123", req.Header.Get("Authorization"))
+			return httpmock.NewStringResponse(200, "{}"), nil
+		})
+
+	app := newTestAppCredentials(t)
+	client := &http.Client{Transport: app.Transport(context.Background(), 12345, nil, nil)}
+
+	resp, err := client.Get("https://api.github.com/repos/owner/repo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+)
+
+// defaultCacheMargin is the minimum remaining lifetime of a cached token for
+// it to be reused
+const defaultCacheMargin = 5 * time.Minute
+
+// tokenCache stores installation tokens on disk so they can be reused by
+// subsequent invocations until shortly before they expire
+type tokenCache struct {
+	dir    string
+	margin time.Duration
+	cipher cacheCipher
+}
+
+// cacheKey identifies a token by everything that affects what it grants
+type cacheKey struct {
+	Hostname       string                           `json:"hostname"`
+	AppID          string                           `json:"app_id"`
+	InstallationID string                           `json:"installation_id"`
+	Options        *github.InstallationTokenOptions `json:"options,omitempty"`
+}
+
+// newTokenCache creates a cache in dir, or in the user's cache directory if
+// dir is empty. Entries are encrypted with cipher unless it is nil.
+func newTokenCache(dir string, margin time.Duration, cipher cacheCipher) (*tokenCache, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the user cache directory: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "gh-token")
+	}
+
+	if margin < 0 {
+		return nil, fmt.Errorf("the cache margin cannot be negative")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache directory %s: %w", dir, err)
+	}
+
+	return &tokenCache{dir: dir, margin: margin, cipher: cipher}, nil
+}
+
+// fetch returns a cached token for key if it is still valid for longer than
+// the cache margin, otherwise it calls generate and caches the new token. An
+// exclusive lock is held on the entry during the whole operation so
+// concurrent processes requesting the same token only generate it once.
+func (tc *tokenCache) fetch(key cacheKey, generate func() (*github.InstallationToken, error)) (*github.InstallationToken, error) {
+	name, err := key.filename()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockFile(filepath.Join(tc.dir, name+".lock"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock cache entry: %w", err)
+	}
+	defer unlock()
+
+	path := filepath.Join(tc.dir, name+".json")
+	token, err := tc.read(path)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		return token, nil
+	}
+
+	token, err = generate()
+	if err != nil {
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil {
+		err = tc.write(path, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// evict removes the entries holding token, for example after it was revoked.
+// Entries that cannot be read are left untouched.
+func (tc *tokenCache) evict(token string) error {
+	paths, err := filepath.Glob(filepath.Join(tc.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("unable to list cache entries: %w", err)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		unlock, err := lockFile(filepath.Join(tc.dir, name+".lock"))
+		if err != nil {
+			return fmt.Errorf("unable to lock cache entry: %w", err)
+		}
+
+		cached, err := tc.read(path)
+		if err == nil && cached.GetToken() == token {
+			err = os.Remove(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				unlock()
+				return fmt.Errorf("unable to remove cache entry: %w", err)
+			}
+		}
+		unlock()
+	}
+
+	return nil
+}
+
+// read returns the token stored at path, or nil if there is no entry or the
+// token expires within the cache margin
+func (tc *tokenCache) read(path string) (*github.InstallationToken, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cache entry: %w", err)
+	}
+
+	if tc.cipher != nil {
+		data, err = tc.cipher.open(data)
+		if err != nil {
+			return nil, fmt.Errorf("refusing to use cache entry %s as it failed authentication, remove it or check the cache encryption settings: %w", path, err)
+		}
+	}
+
+	var token *github.InstallationToken
+	err = json.Unmarshal(data, &token)
+	if err != nil || token == nil || token.Token == nil || token.ExpiresAt == nil {
+		// A corrupted entry is treated as a miss and overwritten
+		return nil, nil
+	}
+
+	if time.Until(token.ExpiresAt.Time) <= tc.margin {
+		return nil, nil
+	}
+
+	return token, nil
+}
+
+// write atomically replaces the entry at path with token
+func (tc *tokenCache) write(path string, token *github.InstallationToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("unable to marshal cache entry: %w", err)
+	}
+
+	if tc.cipher != nil {
+		data, err = tc.cipher.seal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(path, data, 0600)
+}
+
+// filename derives the name of the cache entry from a hash of the key so
+// that no token metadata is visible in the cache directory
+func (k cacheKey) filename() (string, error) {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path and renames it over path, so readers never observe a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	err = tmp.Chmod(perm)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("unable to set permissions of %s: %w", tmp.Name(), err)
+	}
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("unable to write %s: %w", tmp.Name(), err)
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("unable to sync %s: %w", tmp.Name(), err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("unable to close %s: %w", tmp.Name(), err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("unable to rename %s to %s: %w", tmp.Name(), path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// cacheScryptWorkFactor is the scrypt work factor used to derive keys from a
+// cache passphrase. It is lower than age's default to keep cache lookups fast
+// since every read and write of an entry derives the key again.
+const cacheScryptWorkFactor = 15
+
+// keyFileHeader prefixes cache entries encrypted with a key file
+var keyFileHeader = []byte("gh-token-aes-256-gcm-v1\n")
+
+// cacheCipher encrypts and authenticates cache entries at rest
+type cacheCipher interface {
+	seal(plaintext []byte) ([]byte, error)
+	open(ciphertext []byte) ([]byte, error)
+}
+
+// newCacheCipher returns the cipher for the configured passphrase, age
+// identity file or key file, or nil when the cache is not encrypted
+func newCacheCipher(passphrase, identityPath, keyPath string) (cacheCipher, error) {
+	configured := 0
+	for _, option := range []string{passphrase, identityPath, keyPath} {
+		if option != "" {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return nil, fmt.Errorf("only one of --cache-passphrase, --cache-age-identity or --cache-key-file may be specified")
+	}
+
+	switch {
+	case passphrase != "":
+		return newPassphraseCipher(passphrase)
+	case identityPath != "":
+		return newAgeIdentityCipher(identityPath)
+	case keyPath != "":
+		return newKeyFileCipher(keyPath)
+	default:
+		return nil, nil
+	}
+}
+
+// ageCipher encrypts entries with age to a single recipient
+type ageCipher struct {
+	recipient age.Recipient
+	identity  age.Identity
+}
+
+func newPassphraseCipher(passphrase string) (*ageCipher, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use cache passphrase: %w", err)
+	}
+	recipient.SetWorkFactor(cacheScryptWorkFactor)
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use cache passphrase: %w", err)
+	}
+
+	return &ageCipher{recipient: recipient, identity: identity}, nil
+}
+
+func newAgeIdentityCipher(path string) (*ageCipher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read age identity file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse age identity file: %w", err)
+	}
+
+	identity, ok := identities[0].(*age.X25519Identity)
+	if !ok || len(identities) != 1 {
+		return nil, fmt.Errorf("the age identity file must contain a single X25519 identity")
+	}
+
+	return &ageCipher{recipient: identity.Recipient(), identity: identity}, nil
+}
+
+func (a *ageCipher) seal(plaintext []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, a.recipient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt cache entry: %w", err)
+	}
+
+	_, err = w.Write(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt cache entry: %w", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt cache entry: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+func (a *ageCipher) open(ciphertext []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+// keyFileCipher encrypts entries with AES-256-GCM using a key derived from
+// the contents of a key file
+type keyFileCipher struct {
+	aead cipher.AEAD
+}
+
+func newKeyFileCipher(path string) (*keyFileCipher, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cache key file: %w", err)
+	}
+
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("the cache key file must contain at least 32 bytes")
+	}
+
+	key, err := hkdf.Key(sha256.New, secret, nil, "gh-token token cache", 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive cache key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache cipher: %w", err)
+	}
+
+	return &keyFileCipher{aead: aead}, nil
+}
+
+func (k *keyFileCipher) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	out := append(append([]byte{}, keyFileHeader...), nonce...)
+	return k.aead.Seal(out, nonce, plaintext, keyFileHeader), nil
+}
+
+func (k *keyFileCipher) open(ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, keyFileHeader) {
+		return nil, fmt.Errorf("the entry was not encrypted with a key file")
+	}
+	ciphertext = ciphertext[len(keyFileHeader):]
+
+	if len(ciphertext) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("the entry is truncated")
+	}
+	nonce, ciphertext := ciphertext[:k.aead.NonceSize()], ciphertext[k.aead.NonceSize():]
+
+	return k.aead.Open(nil, nonce, ciphertext, keyFileHeader)
+}
@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/google/go-github/v55/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeAgeIdentity generates an age X25519 identity file and returns its path
+func writeAgeIdentity(t *testing.T) string {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "identity.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(identity.String()+"\n"), 0600))
+
+	return path
+}
+
+// writeCacheKeyFile writes a cache key file with the given contents and
+// returns its path
+func writeCacheKeyFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cache.key")
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+
+	return path
+}
+
+func TestNewCacheCipher(t *testing.T) {
+	tests := []struct {
+		name          string
+		passphrase    string
+		identityPath  func(t *testing.T) string
+		keyPath       func(t *testing.T) string
+		expectNil     bool
+		expectedError string
+	}{
+		{
+			name:      "no_encryption",
+			expectNil: true,
+		},
+		{
+			name:       "passphrase",
+			passphrase: "correct horse battery staple",
+		},
+		{
+			name:         "age_identity",
+			identityPath: writeAgeIdentity,
+		},
+		{
+			name: "key_file",
+			keyPath: func(t *testing.T) string {
+				return writeCacheKeyFile(t, "0123456789abcdef0123456789abcdef")
+			},
+		},
+		{
+			name:       "multiple_options",
+			passphrase: "correct horse battery staple",
+			keyPath: func(t *testing.T) string {
+				return writeCacheKeyFile(t, "0123456789abcdef0123456789abcdef")
+			},
+			expectedError: "only one of --cache-passphrase, --cache-age-identity or --cache-key-file may be specified",
+		},
+		{
+			name: "key_file_too_short",
+			keyPath: func(t *testing.T) string {
+				return writeCacheKeyFile(t, "too short")
+			},
+			expectedError: "the cache key file must contain at least 32 bytes",
+		},
+		{
+			name: "missing_key_file",
+			keyPath: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "missing.key")
+			},
+			expectedError: "unable to read cache key file",
+		},
+		{
+			name: "invalid_age_identity",
+			identityPath: func(t *testing.T) string {
+				return writeCacheKeyFile(t, "not an identity")
+			},
+			expectedError: "unable to parse age identity file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identityPath, keyPath string
+			if tt.identityPath != nil {
+				identityPath = tt.identityPath(t)
+			}
+			if tt.keyPath != nil {
+				keyPath = tt.keyPath(t)
+			}
+
+			result, err := newCacheCipher(tt.passphrase, identityPath, keyPath)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, result)
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.expectNil {
+				assert.Nil(t, result)
+				return
+			}
+
+			sealed, err := result.seal([]byte(`{"token":"ghs_secret"}`))
+			assert.NoError(t, err)
+			assert.NotContains(t, string(sealed), "ghs_secret")
+
+			opened, err := result.open(sealed)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"token":"ghs_secret"}`, string(opened))
+		})
+	}
+}
+
+func TestCacheCipherRejectsTamperedEntries(t *testing.T) {
+	keyPath := writeCacheKeyFile(t, "0123456789abcdef0123456789abcdef")
+	otherKeyPath := writeCacheKeyFile(t, "fedcba9876543210fedcba9876543210")
+
+	ciphers := map[string][2]func() (cacheCipher, error){
+		"passphrase": {
+			func() (cacheCipher, error) { return newCacheCipher("first passphrase", "", "") },
+			func() (cacheCipher, error) { return newCacheCipher("second passphrase", "", "") },
+		},
+		"age_identity": {
+			func() (cacheCipher, error) { return newCacheCipher("", writeAgeIdentity(t), "") },
+			func() (cacheCipher, error) { return newCacheCipher("", writeAgeIdentity(t), "") },
+		},
+		"key_file": {
+			func() (cacheCipher, error) { return newCacheCipher("", "", keyPath) },
+			func() (cacheCipher, error) { return newCacheCipher("", "", otherKeyPath) },
+		},
+	}
+
+	for name, constructors := range ciphers {
+		t.Run(name, func(t *testing.T) {
+			c, err := constructors[0]()
+			assert.NoError(t, err)
+			other, err := constructors[1]()
+			assert.NoError(t, err)
+
+			sealed, err := c.seal([]byte(`{"token":"ghs_secret"}`))
+			assert.NoError(t, err)
+
+			t.Run("wrong_key", func(t *testing.T) {
+				_, err := other.open(sealed)
+				assert.Error(t, err)
+			})
+
+			t.Run("modified_ciphertext", func(t *testing.T) {
+				tampered := append([]byte{}, sealed...)
+				tampered[len(tampered)-1] ^= 0xff
+				_, err := c.open(tampered)
+				assert.Error(t, err)
+			})
+
+			t.Run("plaintext_entry", func(t *testing.T) {
+				_, err := c.open([]byte(`{"token":"ghs_secret"}`))
+				assert.Error(t, err)
+			})
+		})
+	}
+}
+
+func TestEncryptedTokenCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newCacheCipher("correct horse battery staple", "", "")
+	assert.NoError(t, err)
+
+	cache, err := newTokenCache(dir, defaultCacheMargin, c)
+	assert.NoError(t, err)
+
+	key := cacheKey{AppID: "123456", InstallationID: "12345"}
+	generate := func() (*github.InstallationToken, error) {
+		return &github.InstallationToken{
+			Token:     github.String("ghs_secret"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}, nil
+	}
+
+	token, err := cache.fetch(key, generate)
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_secret", token.GetToken())
+
+	name, err := key.filename()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "ghs_secret")
+
+	token, err = cache.fetch(key, func() (*github.InstallationToken, error) {
+		t.Fatal("expected the cached token to be reused")
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_secret", token.GetToken())
+
+	other, err := newCacheCipher("wrong passphrase", "", "")
+	assert.NoError(t, err)
+	otherCache, err := newTokenCache(dir, defaultCacheMargin, other)
+	assert.NoError(t, err)
+
+	token, err = otherCache.fetch(key, generate)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed authentication")
+	assert.Nil(t, token)
+}
@@ -0,0 +1,48 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// cacheFlags returns the CLI flags selecting and unlocking the token cache
+func cacheFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:     "cache",
+			Usage:    "Reuse a previously generated token from the local cache while it remains valid, and cache newly generated tokens",
+			Required: false,
+			Aliases:  []string{"c"},
+			Value:    false,
+		},
+		&cli.StringFlag{
+			Name:     "cache-dir",
+			Usage:    "Directory of the token cache, defaults to gh-token in the user cache directory",
+			Required: false,
+			Aliases:  []string{"cache_dir"},
+		},
+		&cli.DurationFlag{
+			Name:     "cache-margin",
+			Usage:    "Minimum remaining lifetime of a cached token for it to be reused, example: 10m",
+			Required: false,
+			Aliases:  []string{"cache_margin"},
+			Value:    defaultCacheMargin,
+		},
+		&cli.StringFlag{
+			Name:     "cache-passphrase",
+			Usage:    "Encrypt cached tokens with a key derived from this passphrase, prefer setting it through the environment",
+			Required: false,
+			Aliases:  []string{"cache_passphrase"},
+			EnvVars:  []string{"GH_TOKEN_CACHE_PASSPHRASE"},
+		},
+		&cli.StringFlag{
+			Name:     "cache-age-identity",
+			Usage:    "Encrypt cached tokens with the X25519 identity in this age identity file",
+			Required: false,
+			Aliases:  []string{"cache_age_identity"},
+		},
+		&cli.StringFlag{
+			Name:     "cache-key-file",
+			Usage:    "Encrypt cached tokens with a key derived from the contents of this file, which must hold at least 32 bytes",
+			Required: false,
+			Aliases:  []string{"cache_key_file"},
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCacheFetch(t *testing.T) {
+	key := cacheKey{
+		Hostname:       "api.github.com",
+		AppID:          "123456",
+		InstallationID: "12345",
+	}
+
+	tests := []struct {
+		name            string
+		margin          time.Duration
+		cached          *github.InstallationToken
+		corrupt         bool
+		generated       *github.InstallationToken
+		generateError   error
+		expectedToken   string
+		expectedCalls   int
+		expectedError   string
+		expectedEntries int
+	}{
+		{
+			name:   "miss_generates_and_stores_token",
+			margin: defaultCacheMargin,
+			generated: &github.InstallationToken{
+				Token:     github.String("ghs_new"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			},
+			expectedToken:   "ghs_new",
+			expectedCalls:   1,
+			expectedEntries: 1,
+		},
+		{
+			name:   "hit_returns_cached_token",
+			margin: defaultCacheMargin,
+			cached: &github.InstallationToken{
+				Token:     github.String("ghs_cached"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(59 * time.Minute)},
+			},
+			expectedToken:   "ghs_cached",
+			expectedCalls:   0,
+			expectedEntries: 1,
+		},
+		{
+			name:   "token_expiring_within_margin_is_replaced",
+			margin: 10 * time.Minute,
+			cached: &github.InstallationToken{
+				Token:     github.String("ghs_cached"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(5 * time.Minute)},
+			},
+			generated: &github.InstallationToken{
+				Token:     github.String("ghs_new"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			},
+			expectedToken:   "ghs_new",
+			expectedCalls:   1,
+			expectedEntries: 1,
+		},
+		{
+			name:    "corrupted_entry_is_replaced",
+			margin:  defaultCacheMargin,
+			corrupt: true,
+			generated: &github.InstallationToken{
+				Token:     github.String("ghs_new"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			},
+			expectedToken:   "ghs_new",
+			expectedCalls:   1,
+			expectedEntries: 1,
+		},
+		{
+			name:   "token_without_expiry_is_not_stored",
+			margin: defaultCacheMargin,
+			generated: &github.InstallationToken{
+				Token: github.String("ghs_new"),
+			},
+			expectedToken:   "ghs_new",
+			expectedCalls:   1,
+			expectedEntries: 0,
+		},
+		{
+			name:            "generation_error_is_returned",
+			margin:          defaultCacheMargin,
+			generateError:   fmt.Errorf("unexpected status code: 403"),
+			expectedCalls:   1,
+			expectedError:   "unexpected status code: 403",
+			expectedEntries: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := newTokenCache(t.TempDir(), tt.margin, nil)
+			assert.NoError(t, err)
+
+			name, err := key.filename()
+			assert.NoError(t, err)
+			path := filepath.Join(cache.dir, name+".json")
+			if tt.cached != nil {
+				data, _ := json.Marshal(tt.cached)
+				assert.NoError(t, os.WriteFile(path, data, 0600))
+			}
+			if tt.corrupt {
+				assert.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+			}
+
+			calls := 0
+			token, err := cache.fetch(key, func() (*github.InstallationToken, error) {
+				calls++
+				return tt.generated, tt.generateError
+			})
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedToken, token.GetToken())
+			}
+			assert.Equal(t, tt.expectedCalls, calls)
+
+			entries, _ := filepath.Glob(filepath.Join(cache.dir, "*.json"))
+			assert.Equal(t, tt.expectedEntries, len(entries))
+		})
+	}
+}
+
+func TestTokenCacheKeys(t *testing.T) {
+	base := cacheKey{Hostname: "api.github.com", AppID: "123456", InstallationID: "12345"}
+	variants := []cacheKey{
+		{Hostname: "github.company.com/api/v3", AppID: "123456", InstallationID: "12345"},
+		{Hostname: "api.github.com", AppID: "654321", InstallationID: "12345"},
+		{Hostname: "api.github.com", AppID: "123456", InstallationID: "67890"},
+		{Hostname: "api.github.com", AppID: "123456", InstallationID: "12345", Options: &github.InstallationTokenOptions{
+			Repositories: []string{"gh-token"},
+		}},
+	}
+
+	baseName, err := base.filename()
+	assert.NoError(t, err)
+	for _, variant := range variants {
+		name, err := variant.filename()
+		assert.NoError(t, err)
+		assert.NotEqual(t, baseName, name)
+	}
+
+	sameName, err := cacheKey{Hostname: "api.github.com", AppID: "123456", InstallationID: "12345"}.filename()
+	assert.NoError(t, err)
+	assert.Equal(t, baseName, sameName)
+}
+
+func TestTokenCachePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := newTokenCache(dir, defaultCacheMargin, nil)
+	assert.NoError(t, err)
+
+	_, err = cache.fetch(cacheKey{AppID: "123456"}, func() (*github.InstallationToken, error) {
+		return &github.InstallationToken{
+			Token:     github.String("ghs_new"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}, nil
+	})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, 2, len(entries), "expected the entry and its lock file")
+	for _, entry := range entries {
+		info, err := os.Stat(entry)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), entry)
+	}
+}
+
+func TestTokenCacheConcurrentFetch(t *testing.T) {
+	cache, err := newTokenCache(t.TempDir(), defaultCacheMargin, nil)
+	assert.NoError(t, err)
+
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := cache.fetch(cacheKey{AppID: "123456"}, func() (*github.InstallationToken, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(10 * time.Millisecond)
+				return &github.InstallationToken{
+					Token:     github.String("ghs_new"),
+					ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+				}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "ghs_new", token.GetToken())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,757 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile holds the named profiles of the configuration file
+type configFile struct {
+	DefaultProfile string              `yaml:"default_profile,omitempty"`
+	Profiles       map[string]*profile `yaml:"profiles,omitempty"`
+}
+
+// profile holds the defaults of the flags of an app. Each field applies to
+// the flag of the same name with dashes.
+type profile struct {
+	AppID          string     `yaml:"app_id,omitempty"`
+	Hostname       string     `yaml:"hostname,omitempty"`
+	CACert         string     `yaml:"ca_cert,omitempty"`
+	ClientCert     string     `yaml:"client_cert,omitempty"`
+	ClientKey      string     `yaml:"client_key,omitempty"`
+	Key            stringList `yaml:"key,omitempty"`
+	KeyDir         string     `yaml:"key_dir,omitempty"`
+	KeyEnv         string     `yaml:"key_env,omitempty"`
+	Base64Key      string     `yaml:"base64_key,omitempty"`
+	KeyAgent       string     `yaml:"key_agent,omitempty"`
+	KeyCommand     string     `yaml:"key_command,omitempty"`
+	PKCS11Module   string     `yaml:"pkcs11_module,omitempty"`
+	PKCS11Token    string     `yaml:"pkcs11_token,omitempty"`
+	PKCS11Key      string     `yaml:"pkcs11_key,omitempty"`
+	InstallationID string     `yaml:"installation_id,omitempty"`
+	Owner          string     `yaml:"owner,omitempty"`
+	Repository     string     `yaml:"repository,omitempty"`
+	Repositories   []string   `yaml:"repositories,omitempty"`
+	Permissions    []string   `yaml:"permissions,omitempty"`
+}
+
+// stringList is a list of strings which may be written as a single string
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = stringList{value.Value}
+		return nil
+	}
+
+	return value.Decode((*[]string)(l))
+}
+
+// profileFlagGroups lists the flags a profile provides defaults for. The
+// flags of a group are only applied when none of them was specified, so that
+// e.g. --owner replaces the installation ID of the profile. --from-git,
+// which no profile sets, replaces both the installation and the hostname.
+var profileFlagGroups = [][]string{
+	{"app-id"},
+	{"hostname", "from-git"},
+	{"ca-cert"},
+	{"client-cert", "client-key"},
+	{"key", "key-dir", "key-env", "key-fd", "base64-key", "key-agent", "key-command", "pkcs11-key"},
+	{"pkcs11-module"},
+	{"pkcs11-token"},
+	{"installation-id", "owner", "repository", "from-git"},
+	{"repositories"},
+	{"permissions"},
+}
+
+// values returns the values of the profile for a flag
+func (p *profile) values(flag string) []string {
+	var values []string
+	switch flag {
+	case "app-id":
+		values = []string{p.AppID}
+	case "hostname":
+		values = []string{p.Hostname}
+	case "ca-cert":
+		values = []string{expandHome(p.CACert)}
+	case "client-cert":
+		values = []string{expandHome(p.ClientCert)}
+	case "client-key":
+		values = []string{expandHome(p.ClientKey)}
+	case "key":
+		for _, key := range p.Key {
+			values = append(values, expandHome(key))
+		}
+	case "key-dir":
+		values = []string{expandHome(p.KeyDir)}
+	case "key-env":
+		values = []string{p.KeyEnv}
+	case "base64-key":
+		values = []string{p.Base64Key}
+	case "key-agent":
+		values = []string{p.KeyAgent}
+	case "key-command":
+		values = []string{p.KeyCommand}
+	case "pkcs11-module":
+		values = []string{p.PKCS11Module}
+	case "pkcs11-token":
+		values = []string{p.PKCS11Token}
+	case "pkcs11-key":
+		values = []string{p.PKCS11Key}
+	case "installation-id":
+		values = []string{p.InstallationID}
+	case "owner":
+		values = []string{p.Owner}
+	case "repository":
+		values = []string{p.Repository}
+	case "repositories":
+		values = p.Repositories
+	case "permissions":
+		values = p.Permissions
+	}
+
+	var nonEmpty []string
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+
+	return nonEmpty
+}
+
+// ApplyProfile sets the flags which were specified neither on the command
+// line nor through their environment variable from the profile selected by
+// --profile, or from the default profile of the configuration file
+func ApplyProfile(c *cli.Context) error {
+	path := configPath()
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("profile")
+	if name == "" {
+		name = config.DefaultProfile
+	}
+	if name == "" {
+		return nil
+	}
+
+	p, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q is not defined in %s", name, path)
+	}
+
+	for _, group := range profileFlagGroups {
+		specified := false
+		for _, flag := range group {
+			specified = specified || c.IsSet(flag)
+		}
+		if specified {
+			continue
+		}
+
+		for _, flag := range group {
+			// Skip the flags the command does not have
+			if c.Value(flag) == nil {
+				continue
+			}
+
+			for _, value := range p.values(flag) {
+				err := c.Set(flag, value)
+				if err != nil {
+					return fmt.Errorf("invalid %s in profile %q: %w", strings.ReplaceAll(flag, "-", "_"), name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// configPath returns the path of the configuration file, GH_TOKEN_CONFIG
+// or config.yml in the gh-token directory of the XDG config directory
+func configPath() string {
+	if path := os.Getenv("GH_TOKEN_CONFIG"); path != "" {
+		return path
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		dir, err = os.UserConfigDir()
+		if err != nil {
+			dir = "."
+		}
+	}
+
+	return filepath.Join(dir, "gh-token", "config.yml")
+}
+
+// loadConfig reads the configuration file, which may not exist
+func loadConfig(path string) (*configFile, error) {
+	config := &configFile{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	// Unknown settings are rejected rather than silently ignored, since
+	// they are most likely typos
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// saveConfig writes the configuration file, creating its directory
+func saveConfig(path string, config *configFile) error {
+	data, err := encodeYAML(config)
+	if err != nil {
+		return fmt.Errorf("unable to encode config file: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("unable to create config directory: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// encodeYAML encodes a value as YAML indented like the examples
+func encodeYAML(value interface{}) ([]byte, error) {
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	err := encoder.Encode(value)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+// expandHome expands a leading ~ to the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// withEnvVars adds a GH_TOKEN_<NAME> environment variable to each flag, the
+// name of the flag in upper case with underscores
+func withEnvVars(flags []cli.Flag) []cli.Flag {
+	for _, flag := range flags {
+		name := flag.Names()[0]
+		envVar := "GH_TOKEN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+		switch f := flag.(type) {
+		case *cli.StringFlag:
+			f.EnvVars = appendEnvVar(f.EnvVars, envVar)
+		case *cli.BoolFlag:
+			f.EnvVars = appendEnvVar(f.EnvVars, envVar)
+		case *cli.IntFlag:
+			f.EnvVars = appendEnvVar(f.EnvVars, envVar)
+		case *cli.DurationFlag:
+			f.EnvVars = appendEnvVar(f.EnvVars, envVar)
+		case *cli.StringSliceFlag:
+			f.EnvVars = appendEnvVar(f.EnvVars, envVar)
+		}
+	}
+
+	return flags
+}
+
+func appendEnvVar(envVars []string, envVar string) []string {
+	for _, existing := range envVars {
+		if existing == envVar {
+			return envVars
+		}
+	}
+
+	return append(envVars, envVar)
+}
+
+// profileSummary describes a profile in the output of config list
+type profileSummary struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+	AppID   string `json:"app_id,omitempty"`
+}
+
+// profileValidation is the result of config validate for a profile
+type profileValidation struct {
+	Profile string   `json:"profile"`
+	Valid   bool     `json:"valid"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// ConfigList is the entrypoint for the config list command
+func ConfigList(c *cli.Context) error {
+	config, err := loadConfig(configPath())
+	if err != nil {
+		return err
+	}
+
+	summaries := []profileSummary{}
+	for _, name := range config.profileNames() {
+		summaries = append(summaries, profileSummary{
+			Name:    name,
+			Default: name == config.DefaultProfile,
+			AppID:   config.Profiles[name].AppID,
+		})
+	}
+
+	bytes, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling profiles to JSON: %w", err)
+	}
+
+	fmt.Println(string(bytes))
+
+	return nil
+}
+
+// ConfigShow is the entrypoint for the config show command
+func ConfigShow(c *cli.Context) error {
+	path := configPath()
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().First()
+	if name == "" {
+		name = config.DefaultProfile
+	}
+	if name == "" {
+		return fmt.Errorf("a profile must be specified since %s has no default_profile", path)
+	}
+
+	p, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q is not defined in %s", name, path)
+	}
+
+	data, err := encodeYAML(p)
+	if err != nil {
+		return fmt.Errorf("failed marshalling profile to YAML: %w", err)
+	}
+
+	fmt.Print(string(data))
+
+	return nil
+}
+
+// ConfigSet is the entrypoint for the config set command
+func ConfigSet(c *cli.Context) error {
+	args := c.Args().Slice()
+	makeDefault := c.Bool("default")
+	if len(args) < 1 || (len(args) < 3 && !(len(args) == 1 && makeDefault)) {
+		return fmt.Errorf("a profile, a setting and its values must be specified, example: gh-token config set work app_id 1122334")
+	}
+
+	path := configPath()
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	if len(args) > 1 {
+		err = config.set(name, args[1], args[2:])
+		if err != nil {
+			return err
+		}
+	} else if _, ok := config.Profiles[name]; !ok {
+		return fmt.Errorf("profile %q is not defined in %s", name, path)
+	}
+
+	if makeDefault {
+		config.DefaultProfile = name
+	}
+
+	return saveConfig(path, config)
+}
+
+// ConfigUnset is the entrypoint for the config unset command
+func ConfigUnset(c *cli.Context) error {
+	args := c.Args().Slice()
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("a profile and optionally a setting must be specified, example: gh-token config unset work installation_id")
+	}
+
+	path := configPath()
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := config.Profiles[args[0]]; !ok {
+		return fmt.Errorf("profile %q is not defined in %s", args[0], path)
+	}
+
+	if len(args) == 2 {
+		err = config.unset(args[0], args[1])
+		if err != nil {
+			return err
+		}
+	} else {
+		delete(config.Profiles, args[0])
+		if config.DefaultProfile == args[0] {
+			config.DefaultProfile = ""
+		}
+	}
+
+	return saveConfig(path, config)
+}
+
+// ConfigValidate is the entrypoint for the config validate command
+func ConfigValidate(c *cli.Context) error {
+	path := configPath()
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	names := c.Args().Slice()
+	if len(names) == 0 {
+		names = config.profileNames()
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("no profile is defined in %s", path)
+	}
+
+	passphrase := keyPassphraseSource(c)
+	pin := c.String("pkcs11-pin")
+
+	validations := make([]profileValidation, 0, len(names))
+	invalid := 0
+	for _, name := range names {
+		var problems []error
+		if p, ok := config.Profiles[name]; ok {
+			problems = p.validate(passphrase, pin)
+		} else {
+			problems = []error{fmt.Errorf("profile is not defined")}
+		}
+
+		validation := profileValidation{Profile: name, Valid: len(problems) == 0}
+		for _, problem := range problems {
+			validation.Errors = append(validation.Errors, problem.Error())
+		}
+		if !validation.Valid {
+			invalid++
+		}
+		validations = append(validations, validation)
+	}
+
+	bytes, err := json.MarshalIndent(validations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling profile validation to JSON: %w", err)
+	}
+
+	fmt.Println(string(bytes))
+
+	if config.DefaultProfile != "" && config.Profiles[config.DefaultProfile] == nil {
+		return fmt.Errorf("default_profile %q is not defined in %s", config.DefaultProfile, path)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d profiles are invalid", invalid, len(validations))
+	}
+
+	return nil
+}
+
+// profileNames returns the names of the profiles in alphabetical order
+func (config *configFile) profileNames() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// set sets a setting of a profile, creating the profile if needed. The other
+// settings of its group are cleared, e.g. setting key_agent removes the key.
+func (config *configFile) set(name, setting string, values []string) error {
+	p := config.Profiles[name]
+	if p == nil {
+		p = &profile{}
+	}
+
+	field, ok := p.setting(setting)
+	if !ok {
+		return fmt.Errorf("unknown setting %q, expected one of %s", setting, strings.Join(profileSettings(), ", "))
+	}
+
+	if field.Kind() != reflect.Slice && len(values) != 1 {
+		return fmt.Errorf("%s takes a single value", setting)
+	}
+
+	err := checkSetting(setting, values)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range settingGroup(setting) {
+		if field, ok := p.setting(other); ok {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+
+	if field.Kind() == reflect.Slice {
+		field.Set(reflect.ValueOf(values).Convert(field.Type()))
+	} else {
+		field.SetString(values[0])
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = map[string]*profile{}
+	}
+	config.Profiles[name] = p
+
+	return nil
+}
+
+// unset clears a setting of a profile
+func (config *configFile) unset(name, setting string) error {
+	field, ok := config.Profiles[name].setting(setting)
+	if !ok {
+		return fmt.Errorf("unknown setting %q, expected one of %s", setting, strings.Join(profileSettings(), ", "))
+	}
+
+	field.Set(reflect.Zero(field.Type()))
+
+	return nil
+}
+
+// setting returns the field of the profile holding a setting
+func (p *profile) setting(name string) (reflect.Value, bool) {
+	value := reflect.ValueOf(p).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if settingName(value.Type().Field(i)) == name {
+			return value.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// profileSettings returns the names of the settings of a profile
+func profileSettings() []string {
+	var names []string
+	fields := reflect.TypeOf(profile{})
+	for i := 0; i < fields.NumField(); i++ {
+		names = append(names, settingName(fields.Field(i)))
+	}
+
+	return names
+}
+
+func settingName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// settingGroup returns the settings which may not be set together with a
+// setting, following the flag groups
+func settingGroup(setting string) []string {
+	for _, group := range profileFlagGroups {
+		var settings []string
+		found := false
+		for _, flag := range group {
+			name := strings.ReplaceAll(flag, "-", "_")
+			found = found || name == setting
+			settings = append(settings, name)
+		}
+		if found {
+			return settings
+		}
+	}
+
+	return nil
+}
+
+// appClientID matches the client IDs of GitHub Apps, e.g. Iv1.0123456789abcdef
+// or Iv23li0123456789abcd, which authenticate apps like their numeric IDs
+var appClientID = regexp.MustCompile(`^Iv[0-9]+\.?[0-9A-Za-z]+$`)
+
+// checkSetting checks the format of the values of a setting
+func checkSetting(setting string, values []string) error {
+	switch setting {
+	case "app_id":
+		for _, value := range values {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if (err != nil || id <= 0) && !appClientID.MatchString(value) {
+				return fmt.Errorf("app_id must be a positive number or a client ID such as Iv1.0123456789abcdef, got %q", value)
+			}
+		}
+	case "installation_id":
+		for _, value := range values {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("%s must be a positive number, got %q", setting, value)
+			}
+		}
+	case "repository":
+		for _, value := range values {
+			_, _, err := splitRepository(value)
+			if err != nil {
+				return err
+			}
+		}
+	case "permissions":
+		_, err := parsePermissions(values)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks the settings of the profile and loads its keys, returning
+// the problems found
+func (p *profile) validate(passphrase keyPassphrase, pin string) []error {
+	var problems []error
+
+	if p.AppID == "" {
+		problems = append(problems, fmt.Errorf("app_id is not set"))
+	}
+
+	for _, setting := range profileSettings() {
+		field, _ := p.setting(setting)
+		var values []string
+		if field.Kind() == reflect.Slice {
+			values = field.Convert(reflect.TypeOf(values)).Interface().([]string)
+		} else if field.String() != "" {
+			values = []string{field.String()}
+		}
+
+		if err := checkSetting(setting, values); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	selectors := 0
+	for _, selector := range []string{p.InstallationID, p.Owner, p.Repository} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		problems = append(problems, fmt.Errorf("only one of installation_id, owner or repository may be set"))
+	}
+
+	_, err := httpOptions{
+		caCert:     expandHome(p.CACert),
+		clientCert: expandHome(p.ClientCert),
+		clientKey:  expandHome(p.ClientKey),
+	}.tlsConfig()
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	keys, err := p.appKeys(passphrase, pin)
+	if err != nil {
+		return append(problems, err)
+	}
+	defer keys.close()
+
+	for _, key := range keys {
+		if _, err := key.get(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", key.name, err))
+		}
+	}
+
+	return problems
+}
+
+// appKeys returns the keys of the key source of the profile
+func (p *profile) appKeys(passphrase keyPassphrase, pin string) (appKeys, error) {
+	sources := 0
+	for _, setting := range []string{p.KeyDir, p.KeyEnv, p.Base64Key, p.KeyAgent, p.KeyCommand, p.PKCS11Key} {
+		if setting != "" {
+			sources++
+		}
+	}
+	if len(p.Key) > 0 {
+		sources++
+	}
+
+	switch {
+	case sources == 0:
+		return nil, fmt.Errorf("no key source is set, expected one of key, key_dir, key_env, base64_key, key_agent, key_command or pkcs11_key")
+	case sources > 1:
+		return nil, fmt.Errorf("only one of key, key_dir, key_env, base64_key, key_agent, key_command or pkcs11_key may be set")
+	}
+
+	paths := p.values("key")
+	if p.KeyEnv != "" {
+		paths = append(paths, "env://"+p.KeyEnv)
+	}
+	if p.KeyDir != "" {
+		dirPaths, err := keyDirPaths(expandHome(p.KeyDir))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, dirPaths...)
+	}
+
+	return keySources{
+		paths:   paths,
+		base64:  p.Base64Key,
+		agent:   p.KeyAgent,
+		command: p.KeyCommand,
+		pkcs11: pkcs11Config{
+			module: p.PKCS11Module,
+			token:  p.PKCS11Token,
+			key:    p.PKCS11Key,
+			pin:    pin,
+		},
+		passphrase: passphrase,
+	}.appKeys()
+}
@@ -0,0 +1,21 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// ConfigSetFlags returns the CLI flags for the config set command
+func ConfigSetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "default",
+			Usage:   "Make the profile the default profile",
+			Aliases: []string{"d"},
+			Value:   false,
+		},
+	}
+}
+
+// ConfigValidateFlags returns the CLI flags for the config validate command,
+// the flags unlocking the keys of the profiles
+func ConfigValidateFlags() []cli.Flag {
+	return withEnvVars(keyUnlockFlags())
+}
@@ -0,0 +1,345 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+const testConfig = `
+default_profile: work
+profiles:
+  work:
+    app_id: "111"
+    key: fixtures/test-private-key.test.pem
+    installation_id: "222"
+    permissions:
+      - contents:read
+      - issues:write
+  enterprise:
+    app_id: "333"
+    hostname: github.example.com
+    key:
+      - old.pem
+      - new.pem
+    owner: octo-org
+`
+
+// runWithProfile runs a command with the generate flags and returns the
+// flag values seen by its action
+func runWithProfile(args []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	app := &cli.App{
+		Commands: []*cli.Command{
+			{
+				Name:   "generate",
+				Flags:  GenerateFlags(),
+				Before: ApplyProfile,
+				Action: func(c *cli.Context) error {
+					for _, name := range []string{"app-id", "installation-id", "owner", "hostname", "base64-key"} {
+						values[name] = c.String(name)
+					}
+					for _, name := range []string{"key", "permissions"} {
+						values[name] = c.StringSlice(name)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	err := app.Run(append([]string{"gh-token", "generate"}, args...))
+	return values, err
+}
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(testConfig), 0600)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		expectedValues map[string]interface{}
+		expectedErr    string
+	}{
+		{
+			name: "default profile",
+			expectedValues: map[string]interface{}{
+				"app-id":          "111",
+				"installation-id": "222",
+				"hostname":        "api.github.com",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
+				"permissions":     []string{"contents:read", "issues:write"},
+			},
+		},
+		{
+			name: "selected profile",
+			args: []string{"--profile", "enterprise"},
+			expectedValues: map[string]interface{}{
+				"app-id":          "333",
+				"installation-id": "",
+				"owner":           "octo-org",
+				"hostname":        "github.example.com",
+				"key":             []string{"old.pem", "new.pem"},
+			},
+		},
+		{
+			name: "profile selected by environment variable",
+			env:  map[string]string{"GH_TOKEN_PROFILE": "enterprise"},
+			expectedValues: map[string]interface{}{
+				"app-id": "333",
+			},
+		},
+		{
+			name: "flags take precedence",
+			args: []string{"--app-id", "999", "--hostname", "ghe.example.com"},
+			expectedValues: map[string]interface{}{
+				"app-id":   "999",
+				"hostname": "ghe.example.com",
+			},
+		},
+		{
+			name: "environment variables take precedence",
+			env:  map[string]string{"GH_TOKEN_APP_ID": "888"},
+			expectedValues: map[string]interface{}{
+				"app-id":          "888",
+				"installation-id": "222",
+			},
+		},
+		{
+			name: "specified key source replaces the profile keys",
+			args: []string{"--base64-key", "a2V5"},
+			expectedValues: map[string]interface{}{
+				"key":        []string(nil),
+				"base64-key": "a2V5",
+			},
+		},
+		{
+			name: "specified owner replaces the profile installation",
+			args: []string{"--owner", "other-org"},
+			expectedValues: map[string]interface{}{
+				"installation-id": "",
+				"owner":           "other-org",
+			},
+		},
+		{
+			name: "from git replaces the profile installation and hostname",
+			args: []string{"--profile", "enterprise", "--from-git"},
+			expectedValues: map[string]interface{}{
+				"app-id":          "333",
+				"installation-id": "",
+				"owner":           "",
+				"hostname":        "api.github.com",
+			},
+		},
+		{
+			name:        "unknown profile",
+			args:        []string{"--profile", "missing"},
+			expectedErr: `profile "missing" is not defined in ` + path,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GH_TOKEN_CONFIG", path)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			values, err := runWithProfile(tt.args)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			for name, expected := range tt.expectedValues {
+				assert.Equal(t, expected, values[name], name)
+			}
+		})
+	}
+}
+
+func TestApplyProfileWithoutConfig(t *testing.T) {
+	t.Setenv("GH_TOKEN_CONFIG", filepath.Join(t.TempDir(), "config.yml"))
+
+	values, err := runWithProfile([]string{"--app-id", "123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", values["app-id"])
+
+	_, err = runWithProfile([]string{"--profile", "work"})
+	assert.ErrorContains(t, err, `profile "work" is not defined`)
+}
+
+func TestConfigPath(t *testing.T) {
+	t.Setenv("GH_TOKEN_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	assert.Equal(t, filepath.Join("/tmp/xdg", "gh-token", "config.yml"), configPath())
+
+	t.Setenv("GH_TOKEN_CONFIG", "/tmp/gh-token.yml")
+	assert.Equal(t, "/tmp/gh-token.yml", configPath())
+}
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		name            string
+		setting         string
+		values          []string
+		expectedProfile *profile
+		expectedErr     string
+	}{
+		{
+			name:            "app ID",
+			setting:         "app_id",
+			values:          []string{"444"},
+			expectedProfile: &profile{AppID: "444", Key: stringList{"old.pem"}, InstallationID: "222"},
+		},
+		{
+			name:            "list setting",
+			setting:         "permissions",
+			values:          []string{"contents:read", "issues:write"},
+			expectedProfile: &profile{AppID: "111", Key: stringList{"old.pem"}, InstallationID: "222", Permissions: []string{"contents:read", "issues:write"}},
+		},
+		{
+			name:            "key source replaces the key",
+			setting:         "key_agent",
+			values:          []string{"SHA256:abc"},
+			expectedProfile: &profile{AppID: "111", KeyAgent: "SHA256:abc", InstallationID: "222"},
+		},
+		{
+			name:            "owner replaces the installation",
+			setting:         "owner",
+			values:          []string{"octo-org"},
+			expectedProfile: &profile{AppID: "111", Key: stringList{"old.pem"}, Owner: "octo-org"},
+		},
+		{
+			name:        "unknown setting",
+			setting:     "apid",
+			values:      []string{"444"},
+			expectedErr: `unknown setting "apid", expected one of app_id, hostname, ca_cert, client_cert, client_key, key, key_dir, key_env, base64_key, key_agent, key_command, pkcs11_module, pkcs11_token, pkcs11_key, installation_id, owner, repository, repositories, permissions`,
+		},
+		{
+			name:        "invalid app ID",
+			setting:     "app_id",
+			values:      []string{"my-app"},
+			expectedErr: `app_id must be a positive number or a client ID such as Iv1.0123456789abcdef, got "my-app"`,
+		},
+		{
+			name:            "app client ID",
+			setting:         "app_id",
+			values:          []string{"Iv23li0123456789abcd"},
+			expectedProfile: &profile{AppID: "Iv23li0123456789abcd", Key: stringList{"old.pem"}, InstallationID: "222"},
+		},
+		{
+			name:        "several values",
+			setting:     "hostname",
+			values:      []string{"a.example.com", "b.example.com"},
+			expectedErr: "hostname takes a single value",
+		},
+		{
+			name:        "invalid repository",
+			setting:     "repository",
+			values:      []string{"octo-repo"},
+			expectedErr: `invalid repository "octo-repo", expected the format owner/name`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configFile{Profiles: map[string]*profile{
+				"work": {AppID: "111", Key: stringList{"old.pem"}, InstallationID: "222"},
+			}}
+
+			err := config.set("work", tt.setting, tt.values)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedProfile, config.Profiles["work"])
+		})
+	}
+}
+
+func TestConfigSetAndUnsetRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gh-token", "config.yml")
+
+	config, err := loadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, config.set("work", "app_id", []string{"111"}))
+	assert.NoError(t, config.set("work", "key", []string{"a.pem", "b.pem"}))
+	config.DefaultProfile = "work"
+	assert.NoError(t, saveConfig(path, config))
+
+	config, err = loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "work", config.DefaultProfile)
+	assert.Equal(t, &profile{AppID: "111", Key: stringList{"a.pem", "b.pem"}}, config.Profiles["work"])
+
+	assert.NoError(t, config.unset("work", "key"))
+	assert.Equal(t, &profile{AppID: "111"}, config.Profiles["work"])
+	assert.EqualError(t, config.unset("work", "keys"), `unknown setting "keys", expected one of app_id, hostname, ca_cert, client_cert, client_key, key, key_dir, key_env, base64_key, key_agent, key_command, pkcs11_module, pkcs11_token, pkcs11_key, installation_id, owner, repository, repositories, permissions`)
+}
+
+func TestLoadConfigRejectsUnknownSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte("profiles:\n  work:\n    appid: \"111\"\n"), 0600)
+	assert.NoError(t, err)
+
+	_, err = loadConfig(path)
+	assert.ErrorContains(t, err, "field appid not found")
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		profile        *profile
+		expectedErrors []string
+	}{
+		{
+			name:    "valid profile",
+			profile: &profile{AppID: "111", Key: stringList{"fixtures/test-private-key.test.pem"}, Permissions: []string{"contents:read"}},
+		},
+		{
+			name:    "missing app ID and key",
+			profile: &profile{},
+			expectedErrors: []string{
+				"app_id is not set",
+				"no key source is set, expected one of key, key_dir, key_env, base64_key, key_agent, key_command or pkcs11_key",
+			},
+		},
+		{
+			name:    "unreadable key",
+			profile: &profile{AppID: "111", Key: stringList{"fixtures/missing.pem"}},
+			expectedErrors: []string{
+				"fixtures/missing.pem: unable to read key file: open fixtures/missing.pem: no such file or directory",
+			},
+		},
+		{
+			name:    "conflicting settings",
+			profile: &profile{AppID: "app", KeyAgent: "SHA256:abc", KeyCommand: "kms-sign", Owner: "octo-org", InstallationID: "222"},
+			expectedErrors: []string{
+				`app_id must be a positive number or a client ID such as Iv1.0123456789abcdef, got "app"`,
+				"only one of installation_id, owner or repository may be set",
+				"only one of key, key_dir, key_env, base64_key, key_agent, key_command or pkcs11_key may be set",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errors []string
+			for _, err := range tt.profile.validate(nil, "") {
+				errors = append(errors, err.Error())
+			}
+
+			assert.Equal(t, tt.expectedErrors, errors)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Credential is the entrypoint for the credential command, implementing
+// git's credential helper protocol
+func Credential(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected exactly one operation: get, store or erase")
+	}
+
+	if slices.Contains(c.StringSlice("key"), "-") || c.String("base64-key") == "-" {
+		return fmt.Errorf("the credential helper cannot read the key from stdin since git writes its request there")
+	}
+
+	return credentialHelper(c, c.Args().First(), os.Stdin, os.Stdout)
+}
+
+func credentialHelper(c *cli.Context, operation string, in io.Reader, out io.Writer) error {
+	request, err := readCredentialRequest(in)
+	if err != nil {
+		return err
+	}
+
+	switch operation {
+	case "get":
+		if !credentialHostMatches(c, request) {
+			// Let git fall back to the next configured helper
+			return nil
+		}
+
+		token, _, err := issueToken(c.Context, c, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "username=x-access-token\n")
+		fmt.Fprintf(out, "password=%s\n", token.GetToken())
+		if token.ExpiresAt != nil {
+			fmt.Fprintf(out, "password_expiry_utc=%d\n", token.ExpiresAt.Unix())
+		}
+
+		return nil
+	case "erase":
+		if !credentialHostMatches(c, request) || request["username"] != "x-access-token" || request["password"] == "" {
+			return nil
+		}
+
+		if c.Bool("cache") {
+			cache, err := openTokenCache(c)
+			if err != nil {
+				return err
+			}
+
+			err = cache.evict(request["password"])
+			if err != nil {
+				return err
+			}
+		}
+
+		// Git erases credentials after they were rejected, in which case the
+		// token is most likely already expired or revoked
+		_ = revokeToken(c.Context, c.String("hostname"), request["password"])
+
+		return nil
+	case "store":
+		// Tokens are generated on demand and never stored
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q, expected get, store or erase", operation)
+	}
+}
+
+// readCredentialRequest parses the key=value attributes git writes to the
+// helper's standard input, up to the first blank line
+func readCredentialRequest(in io.Reader) (map[string]string, error) {
+	request := make(map[string]string)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid credential attribute %q, expected the format key=value", line)
+		}
+		request[key] = value
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credential request: %w", err)
+	}
+
+	return request, nil
+}
+
+// credentialHostMatches reports whether the request is for the GitHub
+// instance gh-token issues tokens for
+func credentialHostMatches(c *cli.Context, request map[string]string) bool {
+	baseURL, err := apiBaseURL(c.String("hostname"))
+	if err != nil {
+		return false
+	}
+
+	scheme, host := gitHost(baseURL)
+
+	return request["protocol"] == scheme && strings.EqualFold(request["host"], host)
+}
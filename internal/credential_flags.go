@@ -0,0 +1,8 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// CredentialFlags returns the CLI flags for the credential command
+func CredentialFlags() []cli.Flag {
+	return tokenFlags()
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialHelper(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: expiresAt},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+
+	tests := []struct {
+		name           string
+		operation      string
+		flags          map[string]interface{}
+		input          string
+		expectedOutput string
+		expectedCalls  map[string]int
+		expectedError  string
+	}{
+		{
+			name:      "get_github_com",
+			operation: "get",
+			input:     "protocol=https\nhost=github.com\npath=octo-org/octo-repo.git\n\n",
+			expectedOutput: fmt.Sprintf("username=x-access-token\npassword=ghs_test_token_123\npassword_expiry_utc=%d\n",
+				expiresAt.Unix()),
+			expectedCalls: map[string]int{
+				"POST https://api.github.com/app/installations/12345/access_tokens": 1,
+			},
+		},
+		{
+			name:      "get_enterprise_server",
+			operation: "get",
+			flags: map[string]interface{}{
+				"hostname": "github.example.com",
+			},
+			input: "protocol=https\nhost=GitHub.Example.com\n",
+			expectedOutput: fmt.Sprintf("username=x-access-token\npassword=ghs_test_token_123\npassword_expiry_utc=%d\n",
+				expiresAt.Unix()),
+			expectedCalls: map[string]int{
+				"POST https://github.example.com/api/v3/app/installations/12345/access_tokens": 1,
+			},
+		},
+		{
+			name:           "get_other_host_is_ignored",
+			operation:      "get",
+			input:          "protocol=https\nhost=gitlab.com\n\n",
+			expectedOutput: "",
+			expectedCalls: map[string]int{
+				"POST https://api.github.com/app/installations/12345/access_tokens": 0,
+			},
+		},
+		{
+			name:           "get_other_protocol_is_ignored",
+			operation:      "get",
+			input:          "protocol=http\nhost=github.com\n\n",
+			expectedOutput: "",
+			expectedCalls: map[string]int{
+				"POST https://api.github.com/app/installations/12345/access_tokens": 0,
+			},
+		},
+		{
+			name:           "erase_revokes_token",
+			operation:      "erase",
+			input:          "protocol=https\nhost=github.com\nusername=x-access-token\npassword=ghs_test_token_123\n\n",
+			expectedOutput: "",
+			expectedCalls: map[string]int{
+				"DELETE https://api.github.com/installation/token": 1,
+			},
+		},
+		{
+			name:           "erase_other_username_is_ignored",
+			operation:      "erase",
+			input:          "protocol=https\nhost=github.com\nusername=octocat\npassword=ghp_personal\n\n",
+			expectedOutput: "",
+			expectedCalls: map[string]int{
+				"DELETE https://api.github.com/installation/token": 0,
+			},
+		},
+		{
+			name:           "store_is_a_no_op",
+			operation:      "store",
+			input:          "protocol=https\nhost=github.com\nusername=x-access-token\npassword=ghs_test_token_123\n\n",
+			expectedOutput: "",
+			expectedCalls: map[string]int{
+				"POST https://api.github.com/app/installations/12345/access_tokens": 0,
+				"DELETE https://api.github.com/installation/token":                  0,
+			},
+		},
+		{
+			name:          "unknown_operation",
+			operation:     "approve",
+			input:         "protocol=https\nhost=github.com\n\n",
+			expectedError: "unknown operation \"approve\"",
+		},
+		{
+			name:          "malformed_input",
+			operation:     "get",
+			input:         "protocol https\n\n",
+			expectedError: "invalid credential attribute \"protocol https\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+				httpmock.NewStringResponder(201, string(tokenJSON)))
+			httpmock.RegisterResponder("POST", "https://github.example.com/api/v3/app/installations/12345/access_tokens",
+				httpmock.NewStringResponder(201, string(tokenJSON)))
+			httpmock.RegisterResponder("DELETE", "https://api.github.com/installation/token",
+				httpmock.NewStringResponder(204, ""))
+
+			var out bytes.Buffer
+			ctx := createTestContextForExec(tt.flags, nil)
+			err := credentialHelper(ctx, tt.operation, strings.NewReader(tt.input), &out)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedOutput, out.String())
+			}
+
+			info := httpmock.GetCallCountInfo()
+			for call, count := range tt.expectedCalls {
+				assert.Equal(t, count, info[call], call)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperEraseEvictsCachedToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tokens := []string{"ghs_first", "ghs_second"}
+	calls := 0
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		func(_ *http.Request) (*http.Response, error) {
+			token := &github.InstallationToken{
+				Token:     github.String(tokens[calls]),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			}
+			calls++
+			return httpmock.NewJsonResponse(201, token)
+		})
+	httpmock.RegisterResponder("DELETE", "https://api.github.com/installation/token",
+		httpmock.NewStringResponder(401, `{"message": "Bad credentials"}`))
+
+	cacheDir := t.TempDir()
+	flags := map[string]interface{}{
+		"cache":     true,
+		"cache-dir": cacheDir,
+	}
+	get := func() string {
+		var out bytes.Buffer
+		err := credentialHelper(createTestContextForExec(flags, nil), "get", strings.NewReader("protocol=https\nhost=github.com\n\n"), &out)
+		assert.NoError(t, err)
+		return out.String()
+	}
+
+	assert.Contains(t, get(), "password=ghs_first\n")
+	assert.Contains(t, get(), "password=ghs_first\n")
+
+	var out bytes.Buffer
+	err := credentialHelper(createTestContextForExec(flags, nil), "erase",
+		strings.NewReader("protocol=https\nhost=github.com\nusername=x-access-token\npassword=ghs_first\n\n"), &out)
+	assert.NoError(t, err)
+
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	assert.Equal(t, 0, len(entries))
+
+	assert.Contains(t, get(), "password=ghs_second\n")
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// defaultTokenEnvVars are the environment variables the token is exposed as
+// to the child process when --env-var is not specified
+var defaultTokenEnvVars = []string{"GH_TOKEN", "GITHUB_TOKEN"}
+
+// Exec is the entrypoint for the exec command
+func Exec(c *cli.Context) error {
+	args := c.Args().Slice()
+	envVars := c.StringSlice("env-var")
+	revoke := c.Bool("revoke")
+
+	if len(args) == 0 {
+		return fmt.Errorf("a command to run must be specified, example: gh-token exec --app-id 1122334 --key key.pem -- terraform apply")
+	}
+
+	if revoke && c.Bool("cache") {
+		return fmt.Errorf("--revoke cannot be combined with --cache since the revoked token would remain cached")
+	}
+
+	if len(envVars) == 0 {
+		envVars = defaultTokenEnvVars
+	}
+	for _, name := range envVars {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+
+	token, hostname, err := issueToken(c.Context, c, nil)
+	if err != nil {
+		return err
+	}
+
+	exitCode, runErr := runWithToken(args, envVars, token.GetToken())
+
+	if revoke {
+		// Revoke the token even when gh-token was interrupted along with
+		// the command
+		err = revokeToken(context.WithoutCancel(c.Context), hostname, token.GetToken())
+		if err != nil {
+			revokeErr := fmt.Errorf("failed revoking installation token: %w", err)
+			if runErr != nil {
+				return errors.Join(runErr, revokeErr)
+			}
+			if exitCode == 0 {
+				return revokeErr
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", revokeErr)
+		}
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+
+	if exitCode != 0 {
+		return cli.Exit("", exitCode)
+	}
+
+	return nil
+}
+
+// runWithToken runs a command with the token exposed through envVars,
+// forwarding the signals received by gh-token to it, and returns its exit
+// code. When the command shares the foreground process group of the
+// terminal, the signals typed on the terminal already reach it and are not
+// forwarded a second time, since programs such as terraform abort abruptly
+// on a second interrupt.
+func runWithToken(args, envVars []string, token string) (int, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = tokenEnv(os.Environ(), envVars, token)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	err := cmd.Start()
+	if err != nil {
+		return 0, fmt.Errorf("unable to start %s: %w", args[0], err)
+	}
+
+	foreground := inForegroundProcessGroup()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if foreground && slices.Contains(terminalSignals, sig) {
+					continue
+				}
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCode(exitErr.ProcessState), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed waiting for %s: %w", args[0], err)
+	}
+
+	return 0, nil
+}
+
+// tokenEnv returns environ with every variable in envVars set to token,
+// replacing any existing value
+func tokenEnv(environ, envVars []string, token string) []string {
+	env := make([]string, 0, len(environ)+len(envVars))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if !slices.Contains(envVars, name) {
+			env = append(env, entry)
+		}
+	}
+
+	for _, envVar := range envVars {
+		env = append(env, fmt.Sprintf("%s=%s", envVar, token))
+	}
+
+	return env
+}
@@ -0,0 +1,21 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// ExecFlags returns the CLI flags for the exec command
+func ExecFlags() []cli.Flag {
+	return append(tokenFlags(),
+		&cli.StringSliceFlag{
+			Name:     "env-var",
+			Usage:    "Name of the environment variable the token is exposed as to the command, can be repeated. Defaults to GH_TOKEN and GITHUB_TOKEN",
+			Required: false,
+			Aliases:  []string{"e", "env_var"},
+		},
+		&cli.BoolFlag{
+			Name:     "revoke",
+			Usage:    "Revoke the token once the command exits",
+			Required: false,
+			Value:    false,
+		},
+	)
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+// createTestContextForExec creates a test CLI context with the given flags
+// and command arguments for the exec command
+func createTestContextForExec(flags map[string]interface{}, args []string) *cli.Context {
+	app := &cli.App{}
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	// Set default values
+	defaults := map[string]interface{}{
+		"app-id":          "123456",
+		"installation-id": "12345",
+		"key":             []string{"fixtures/test-private-key.test.pem"},
+		"hostname":        "api.github.com",
+		"revoke":          false,
+		"cache":           false,
+	}
+
+	// Override with test-specific flags
+	for k, v := range flags {
+		defaults[k] = v
+	}
+
+	// Set up flags based on type
+	for key, value := range defaults {
+		switch v := value.(type) {
+		case string:
+			set.String(key, v, "")
+		case bool:
+			set.Bool(key, v, "")
+		case int:
+			set.Int(key, v, "")
+		case []string:
+			set.Var(cli.NewStringSlice(v...), key, "")
+		}
+	}
+	_ = set.Parse(args)
+
+	return cli.NewContext(app, set, nil)
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands require a POSIX shell")
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+
+	tests := []struct {
+		name             string
+		flags            map[string]interface{}
+		args             []string
+		revokeStatus     int
+		expectedExitCode int
+		expectedRevokes  int
+		expectedError    string
+	}{
+		{
+			name:             "token_is_exposed_with_default_variables",
+			args:             []string{"sh", "-c", `test "$GH_TOKEN" = ghs_test_token_123 && test "$GITHUB_TOKEN" = ghs_test_token_123`},
+			expectedExitCode: 0,
+		},
+		{
+			name: "token_is_exposed_with_custom_variables",
+			flags: map[string]interface{}{
+				"env-var": []string{"TF_VAR_github_token"},
+			},
+			args:             []string{"sh", "-c", `test "$TF_VAR_github_token" = ghs_test_token_123 && test -z "$GH_TOKEN"`},
+			expectedExitCode: 0,
+		},
+		{
+			name:             "exit_code_is_forwarded",
+			args:             []string{"sh", "-c", "exit 3"},
+			expectedExitCode: 3,
+		},
+		{
+			name:             "signal_is_reported_as_exit_code",
+			args:             []string{"sh", "-c", "kill -TERM $$"},
+			expectedExitCode: 143,
+		},
+		{
+			name: "token_is_revoked_after_exit",
+			flags: map[string]interface{}{
+				"revoke": true,
+			},
+			args:             []string{"sh", "-c", "exit 0"},
+			revokeStatus:     204,
+			expectedExitCode: 0,
+			expectedRevokes:  1,
+		},
+		{
+			name: "token_is_revoked_after_failure",
+			flags: map[string]interface{}{
+				"revoke": true,
+			},
+			args:             []string{"sh", "-c", "exit 2"},
+			revokeStatus:     204,
+			expectedExitCode: 2,
+			expectedRevokes:  1,
+		},
+		{
+			name: "revocation_failure_is_reported",
+			flags: map[string]interface{}{
+				"revoke": true,
+			},
+			args:            []string{"sh", "-c", "exit 0"},
+			revokeStatus:    401,
+			expectedRevokes: 1,
+			expectedError:   "failed revoking installation token",
+		},
+		{
+			name:          "missing_command",
+			args:          []string{},
+			expectedError: "a command to run must be specified",
+		},
+		{
+			name:          "command_not_found",
+			args:          []string{"gh-token-command-that-does-not-exist"},
+			expectedError: "unable to start gh-token-command-that-does-not-exist",
+		},
+		{
+			name: "revoke_with_cache",
+			flags: map[string]interface{}{
+				"revoke": true,
+				"cache":  true,
+			},
+			args:          []string{"true"},
+			expectedError: "--revoke cannot be combined with --cache",
+		},
+		{
+			name: "invalid_variable_name",
+			flags: map[string]interface{}{
+				"env-var": []string{"GH TOKEN"},
+			},
+			args:          []string{"true"},
+			expectedError: "invalid environment variable name \"GH TOKEN\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+				httpmock.NewStringResponder(201, string(tokenJSON)))
+			httpmock.RegisterResponder("DELETE", "https://api.github.com/installation/token",
+				func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, "Bearer ghs_test_token_123", req.Header.Get("Authorization"))
+					return httpmock.NewStringResponse(tt.revokeStatus, ""), nil
+				})
+
+			err := Exec(createTestContextForExec(tt.flags, tt.args))
+
+			switch {
+			case tt.expectedError != "":
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			case tt.expectedExitCode != 0:
+				exitCoder, ok := err.(cli.ExitCoder)
+				assert.True(t, ok, "expected an exit code error, got %v", err)
+				if ok {
+					assert.Equal(t, tt.expectedExitCode, exitCoder.ExitCode())
+				}
+			default:
+				assert.NoError(t, err)
+			}
+
+			info := httpmock.GetCallCountInfo()
+			assert.Equal(t, tt.expectedRevokes, info["DELETE https://api.github.com/installation/token"])
+		})
+	}
+}
+
+func TestTokenEnv(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "GH_TOKEN=old", "HOME=/home/user", "GITHUB_TOKEN_EXTRA=keep"}
+
+	result := tokenEnv(environ, []string{"GH_TOKEN", "GITHUB_TOKEN"}, "ghs_new")
+
+	assert.Equal(t, []string{
+		"PATH=/usr/bin",
+		"HOME=/home/user",
+		"GITHUB_TOKEN_EXTRA=keep",
+		"GH_TOKEN=ghs_new",
+		"GITHUB_TOKEN=ghs_new",
+	}, result)
+}
@@ -1,67 +1,28 @@
 package internal
 
 import (
-	"crypto/rsa"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strconv"
-	"strings"
 
+	"github.com/Link-/gh-token/ghtoken"
 	"github.com/google/go-github/v55/github"
 	"github.com/urfave/cli/v2"
 )
 
 // Generate is the entrypoint for the generate command
 func Generate(c *cli.Context) error {
-	appID := c.String("app-id")
-	installationID := c.String("installation-id")
-	keyPath := c.String("key")
-	keyBase64 := c.String("base64-key")
 	printJWT := c.Bool("jwt")
-	jwtExpiry := c.Int("duration")
-	hostname := strings.ToLower(c.String("hostname"))
 	tokenOnly := c.Bool("token-only")
 	silent := c.Bool("silent")
 
-	if keyPath == "" && keyBase64 == "" {
-		return fmt.Errorf("either --key or --base64-key must be specified")
-	}
-
-	if keyPath != "" && keyBase64 != "" {
-		return fmt.Errorf("only one of --key or --base64-key may be specified")
-	}
-
-	if hostname != "api.github.com" && !strings.Contains(hostname, "/api/v3") {
-		endpoint := fmt.Sprintf("%s/api/v3", hostname)
-		hostname = strings.TrimSuffix(endpoint, "/")
-	}
-
-	if jwtExpiry < 1 || jwtExpiry > 10 {
-		jwtExpiry = 10
-	}
-
-	var err error
-	var privateKey *rsa.PrivateKey
-	if keyPath != "" {
-		privateKey, err = readKey(keyPath)
-		if err != nil {
-			return err
-		}
-	} else {
-		privateKey, err = readKeyBase64(keyBase64)
+	if printJWT {
+		jsonWebToken, err := appJWT(c)
 		if err != nil {
 			return err
 		}
-	}
 
-	jsonWebToken, err := generateJWT(appID, jwtExpiry, privateKey)
-	if err != nil {
-		return fmt.Errorf("failed generating JWT: %w", err)
-	}
-
-	if printJWT {
 		if !silent {
 			fmt.Println(jsonWebToken)
 		}
@@ -69,16 +30,9 @@ func Generate(c *cli.Context) error {
 		return nil
 	}
 
-	if installationID == "" {
-		installationID, err = retrieveDefaultInstallationID(hostname, jsonWebToken)
-		if err != nil {
-			return fmt.Errorf("failed retrieving default installation ID: %w", err)
-		}
-	}
-
-	token, err := generateToken(hostname, jsonWebToken, installationID)
+	token, _, err := issueToken(c.Context, c, nil)
 	if err != nil {
-		return fmt.Errorf("failed generating installation token: %w", err)
+		return err
 	}
 
 	if !silent {
@@ -97,78 +51,251 @@ func Generate(c *cli.Context) error {
 	return nil
 }
 
-func retrieveDefaultInstallationID(hostname, jwt string) (string, error) {
-	endpoint := fmt.Sprintf("https://%s/app/installations?per_page=1", hostname)
-	req, err := http.NewRequest("GET", endpoint, nil)
+// appJWT signs a JWT for the app with the first of its keys
+func appJWT(c *cli.Context) (string, error) {
+	keys, err := loadAppKeys(c)
+	if err != nil {
+		return "", err
+	}
+	defer keys.close()
+
+	signer, err := keys[0].get()
+	if err != nil {
+		return "", err
+	}
+
+	appID, err := requireAppID(c)
 	if err != nil {
-		return "", fmt.Errorf("unable to create GET request to %s: %w", endpoint, err)
+		return "", err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
-	req.Header.Add("Accept", "application/vnd.github+json")
-	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-	req.Header.Add("User-Agent", "Link-/gh-token")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	jsonWebToken, err := generateJWT(appID, appJWTExpiry(c), signer)
 	if err != nil {
-		return "", fmt.Errorf("unable to GET %s: %w", endpoint, err)
+		return "", fmt.Errorf("failed generating JWT: %w", err)
+	}
+
+	return jsonWebToken, nil
+}
+
+// requireAppID returns the app ID, which may come from the flags, the
+// environment or a profile
+func requireAppID(c *cli.Context) (string, error) {
+	appID := c.String("app-id")
+	if appID == "" {
+		return "", fmt.Errorf("--app-id must be specified, or set in the selected profile of the config file")
+	}
+
+	return appID, nil
+}
+
+// appJWTExpiry returns the lifetime in minutes of the app JWTs, from 1 to
+// 10 minutes
+func appJWTExpiry(c *cli.Context) int {
+	jwtExpiry := c.Int("duration")
+	if jwtExpiry < 1 || jwtExpiry > 10 {
+		jwtExpiry = 10
+	}
+
+	return jwtExpiry
+}
+
+// issueToken generates an installation token, or reuses a cached one, from
+// the flags shared by the commands handing out tokens. The JWT is signed with
+// keys, or with the keys of the flags when nil. It returns the token and the
+// API hostname it was issued by.
+func issueToken(ctx context.Context, c *cli.Context, keys appKeys) (*github.InstallationToken, string, error) {
+	appID := c.String("app-id")
+	installationID := c.String("installation-id")
+	owner := c.String("owner")
+	repository := c.String("repository")
+	hostname := c.String("hostname")
+	repositories := c.StringSlice("repositories")
+	repositoryIDs := c.StringSlice("repository-ids")
+	permissions := c.StringSlice("permissions")
+	fromGit := c.Bool("from-git")
+	gitRemoteName := c.String("git-remote")
+	scopeRepository := c.Bool("scope-repository")
+	useCache := c.Bool("cache")
+	criteria := installationCriteria{
+		AccountType:  c.String("account-type"),
+		AccountLogin: c.String("account-login"),
+		First:        c.Bool("first"),
+	}
+
+	selectors := 0
+	for _, selector := range []string{installationID, owner, repository} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return nil, "", fmt.Errorf("only one of --installation-id, --owner or --repository may be specified")
+	}
+
+	if fromGit {
+		if gitRemoteName == "" {
+			gitRemoteName = "origin"
+		}
+
+		remoteURL, err := readGitRemoteURL("", gitRemoteName)
+		if err != nil {
+			return nil, "", err
+		}
+
+		remote, err := parseRemoteURL(remoteURL)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// Explicit flags always take precedence over the inferred values
+		if !c.IsSet("hostname") {
+			hostname = remote.apiHostname()
+		}
+		if selectors == 0 {
+			repository = remote.fullName()
+		}
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if scopeRepository {
+		if repository == "" {
+			return nil, "", fmt.Errorf("--scope-repository requires --repository or --from-git")
+		}
+
+		_, name, err := splitRepository(repository)
+		if err != nil {
+			return nil, "", err
+		}
+		repositories = append(repositories, name)
 	}
 
-	var response []github.Installation
-	bytes, err := io.ReadAll(resp.Body)
+	hostname, err := apiBaseURL(hostname)
 	if err != nil {
-		return "", fmt.Errorf("unable to read response body: %w", err)
+		return nil, "", err
 	}
 
-	err = json.Unmarshal(bytes, &response)
+	tokenOptions, err := installationTokenOptions(repositories, repositoryIDs, permissions)
 	if err != nil {
-		return "", fmt.Errorf("unable to unmarshal response body: %w", err)
+		return nil, "", err
 	}
 
-	return strconv.FormatInt(*response[0].ID, 10), nil
+	if _, err := requireAppID(c); err != nil {
+		return nil, "", err
+	}
+
+	if keys == nil {
+		keys, err = loadAppKeys(c)
+		if err != nil {
+			return nil, "", err
+		}
+		defer keys.close()
+	}
+
+	var token *github.InstallationToken
+	err = keys.withJWT(appID, appJWTExpiry(c), func(jsonWebToken string) error {
+		var err error
+		installationID := installationID
+		switch {
+		case installationID != "":
+		case owner != "":
+			installationID, err = retrieveOwnerInstallationID(ctx, hostname, jsonWebToken, owner)
+			if err != nil {
+				return fmt.Errorf("failed retrieving installation ID for owner: %w", err)
+			}
+		case repository != "":
+			installationID, err = retrieveRepositoryInstallationID(ctx, hostname, jsonWebToken, repository)
+			if err != nil {
+				return fmt.Errorf("failed retrieving installation ID for repository: %w", err)
+			}
+		default:
+			installationID, err = retrieveDefaultInstallationID(ctx, hostname, jsonWebToken, criteria)
+			if err != nil {
+				return fmt.Errorf("failed retrieving default installation ID: %w", err)
+			}
+		}
+
+		if useCache {
+			cache, err := openTokenCache(c)
+			if err != nil {
+				return err
+			}
+
+			key := cacheKey{
+				Hostname:       hostname,
+				AppID:          appID,
+				InstallationID: installationID,
+				Options:        tokenOptions,
+			}
+			token, err = cache.fetch(key, func() (*github.InstallationToken, error) {
+				return generateToken(ctx, hostname, jsonWebToken, installationID, tokenOptions)
+			})
+			if err != nil {
+				return fmt.Errorf("failed generating installation token: %w", err)
+			}
+		} else {
+			token, err = generateToken(ctx, hostname, jsonWebToken, installationID, tokenOptions)
+			if err != nil {
+				return fmt.Errorf("failed generating installation token: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, hostname, nil
 }
 
-func generateToken(hostname, jwt, installationID string) (*github.InstallationToken, error) {
-	endpoint := fmt.Sprintf("https://%s/app/installations/%s/access_tokens", hostname, installationID)
-	req, err := http.NewRequest("POST", endpoint, nil)
+// openTokenCache opens the token cache configured by the --cache-* flags
+func openTokenCache(c *cli.Context) (*tokenCache, error) {
+	cacheDir := c.String("cache-dir")
+	cacheMargin := c.Duration("cache-margin")
+	cachePassphrase := c.String("cache-passphrase")
+	cacheIdentity := c.String("cache-age-identity")
+	cacheKeyFile := c.String("cache-key-file")
+
+	cipher, err := newCacheCipher(cachePassphrase, cacheIdentity, cacheKeyFile)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create POST request to %s: %w", endpoint, err)
+		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
-	req.Header.Add("Accept", "application/vnd.github+json")
-	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-	req.Header.Add("User-Agent", "Link-/gh-token")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return newTokenCache(cacheDir, cacheMargin, cipher)
+}
+
+// retrieveDefaultInstallationID lists the installations of the app and
+// selects one according to the given criteria
+func retrieveDefaultInstallationID(ctx context.Context, hostname, jwt string, criteria installationCriteria) (string, error) {
+	installations, err := listInstallations(ctx, hostname, jwt)
 	if err != nil {
-		return nil, fmt.Errorf("unable to POST to %s: %w", endpoint, err)
+		return "", err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
 
-	if resp.StatusCode != 201 {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	installation, err := selectInstallation(*installations, criteria)
+	if err != nil {
+		return "", err
 	}
 
-	var response *github.InstallationToken
-	bytes, err := io.ReadAll(resp.Body)
+	return strconv.FormatInt(installation.GetID(), 10), nil
+}
+
+func generateToken(ctx context.Context, hostname, jwt, installationID string, options *github.InstallationTokenOptions) (*github.InstallationToken, error) {
+	id, err := strconv.ParseInt(installationID, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %w", err)
+		return nil, fmt.Errorf("invalid installation ID %q", installationID)
 	}
 
-	err = json.Unmarshal(bytes, &response)
+	return apiClient(hostname).CreateInstallationToken(ctx, jwt, id, options)
+}
+
+// apiClient returns a client for the API of the GitHub instance given by
+// --hostname, or by the base URL it was resolved to
+func apiClient(hostname string) *ghtoken.Client {
+	baseURL, err := apiBaseURL(hostname)
 	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal response body: %w", err)
+		// Commands resolve the hostname up front and report invalid ones
+		baseURL = "https://" + hostname
 	}
 
-	return response, nil
+	return &ghtoken.Client{BaseURL: baseURL, HTTPClient: httpClient}
 }
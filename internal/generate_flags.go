@@ -1,37 +1,102 @@
 package internal
 
-import "github.com/urfave/cli/v2"
+import (
+	"slices"
+
+	"github.com/urfave/cli/v2"
+)
 
 // GenerateFlags returns the CLI flags for the generate command
 func GenerateFlags() []cli.Flag {
-	return []cli.Flag{
+	return withEnvVars(slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:     "app-id",
 			Usage:    "GitHub App ID",
-			Required: true,
+			Required: false,
 			Aliases:  []string{"i", "app_id"},
 		},
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "Name of the configuration file profile providing the defaults of the other flags",
+			Required: false,
+			EnvVars:  []string{"GH_TOKEN_PROFILE"},
+		},
 		&cli.StringFlag{
 			Name:     "installation-id",
-			Usage:    "GitHub App installation ID. Defaults to the first installation returned by the GitHub API if not specified",
+			Usage:    "GitHub App installation ID. If not specified, the app's only installation matching --account-type and --account-login is used",
 			Required: false,
 			Aliases:  []string{"l", "installation_id"},
 		},
 		&cli.StringFlag{
-			Name:     "key",
-			Usage:    "Path to private key",
+			Name:     "owner",
+			Usage:    "Organization or user login whose installation should be used instead of --installation-id",
 			Required: false,
-			Aliases:  []string{"k"},
 		},
 		&cli.StringFlag{
-			Name:     "base64-key",
-			Usage:    "A base64 encoded private key",
+			Name:     "repository",
+			Usage:    "Repository in the owner/name format whose installation should be used instead of --installation-id",
 			Required: false,
-			Aliases:  []string{"b", "base64_key"},
+		},
+		&cli.StringFlag{
+			Name:     "account-type",
+			Usage:    "Only consider installations on accounts of this type when no installation is specified, either User or Organization",
+			Required: false,
+			Aliases:  []string{"account_type"},
+		},
+		&cli.StringFlag{
+			Name:     "account-login",
+			Usage:    "Only consider installations on the account with this login when no installation is specified",
+			Required: false,
+			Aliases:  []string{"account_login"},
+		},
+		&cli.BoolFlag{
+			Name:     "first",
+			Usage:    "Use the first matching installation instead of failing when the app has several installations",
+			Required: false,
+			Value:    false,
+		},
+		&cli.BoolFlag{
+			Name:     "from-git",
+			Usage:    "Infer the hostname and the repository installation from the git remote of the current directory",
+			Required: false,
+			Aliases:  []string{"g", "from_git"},
+			Value:    false,
+		},
+		&cli.StringFlag{
+			Name:     "git-remote",
+			Usage:    "Name of the git remote read by --from-git",
+			Required: false,
+			Aliases:  []string{"git_remote"},
+			Value:    "origin",
+		},
+		&cli.BoolFlag{
+			Name:     "scope-repository",
+			Usage:    "Restrict the token to the repository given with --repository or inferred with --from-git",
+			Required: false,
+			Aliases:  []string{"scope_repository"},
+			Value:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:     "repositories",
+			Usage:    "Restrict the token to these repository names (without the owner), can be repeated or comma separated",
+			Required: false,
+			Aliases:  []string{"r"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "repository-ids",
+			Usage:    "Restrict the token to these repository IDs, can be repeated or comma separated",
+			Required: false,
+			Aliases:  []string{"repository_ids"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "permissions",
+			Usage:    "Restrict the token to these permissions as name:level pairs, example: contents:read,issues:write",
+			Required: false,
+			Aliases:  []string{"p"},
 		},
 		&cli.StringFlag{
 			Name:     "hostname",
-			Usage:    "GitHub Enterprise Server API endpoint, example: github.example.com",
+			Usage:    "GitHub Enterprise Server or GHE.com host, or API URL, example: github.example.com, octocorp.ghe.com or http://localhost:8080/api/v3",
 			Required: false,
 			Aliases:  []string{"o"},
 			Value:    "api.github.com",
@@ -62,5 +127,20 @@ func GenerateFlags() []cli.Flag {
 			Aliases: []string{"s"},
 			Value:   false,
 		},
+	}, keyFlags(), cacheFlags(), httpFlags()))
+}
+
+// tokenFlags returns the flags of the generate command used to issue a
+// token, without the ones controlling how it is printed
+func tokenFlags() []cli.Flag {
+	var flags []cli.Flag
+	for _, flag := range GenerateFlags() {
+		switch flag.Names()[0] {
+		case "jwt", "duration", "token-only", "silent":
+			continue
+		}
+		flags = append(flags, flag)
 	}
+
+	return flags
 }
@@ -1,11 +1,15 @@
 package internal
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -24,7 +28,7 @@ func createTestContext(flags map[string]interface{}) *cli.Context {
 	defaults := map[string]interface{}{
 		"app-id":          "",
 		"installation-id": "",
-		"key":             "",
+		"key":             []string{},
 		"base64-key":      "",
 		"jwt":             false,
 		"jwt-expiry":      10,
@@ -47,6 +51,8 @@ func createTestContext(flags map[string]interface{}) *cli.Context {
 			set.Bool(key, v, "")
 		case int:
 			set.Int(key, v, "")
+		case []string:
+			set.Var(cli.NewStringSlice(v...), key, "")
 		}
 	}
 
@@ -93,7 +99,7 @@ func TestGenerate(t *testing.T) {
 			flags: map[string]interface{}{
 				"app-id":          "123456",
 				"installation-id": "12345",
-				"key":             "fixtures/test-private-key.test.pem",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
 				"hostname":        "api.github.com",
 				"jwt-expiry":      10,
 				"silent":          true,
@@ -124,24 +130,56 @@ func TestGenerate(t *testing.T) {
 			name: "successful_with_auto_installation_id",
 			flags: map[string]interface{}{
 				"app-id":     "123456",
-				"key":        "fixtures/test-private-key.test.pem",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
 				"hostname":   "api.github.com",
 				"jwt-expiry": 10,
 				"silent":     true,
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=1",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(installationJSON)))
 				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
 					httpmock.NewStringResponder(201, string(tokenJSON)))
 			},
 			expectedError: "",
 		},
+		{
+			name: "successful_with_owner",
+			flags: map[string]interface{}{
+				"app-id": "123456",
+				"key":    []string{"fixtures/test-private-key.test.pem"},
+				"owner":  "octo-org",
+				"silent": true,
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/orgs/octo-org/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(201, string(tokenJSON)))
+			},
+			expectedError: "",
+		},
+		{
+			name: "successful_with_repository",
+			flags: map[string]interface{}{
+				"app-id":     "123456",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
+				"repository": "octo-org/octo-repo",
+				"silent":     true,
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/repos/octo-org/octo-repo/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(201, string(tokenJSON)))
+			},
+			expectedError: "",
+		},
 		{
 			name: "successful_jwt_only",
 			flags: map[string]interface{}{
 				"app-id":     "123456",
-				"key":        "fixtures/test-private-key.test.pem",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
 				"jwt":        true,
 				"jwt-expiry": 10,
 				"silent":     true,
@@ -155,23 +193,47 @@ func TestGenerate(t *testing.T) {
 				"app-id": "123456",
 			},
 			setupMocks:    func() {},
-			expectedError: "either --key or --base64-key must be specified",
+			expectedError: "either --key, --base64-key, --key-agent, --pkcs11-key or --key-command must be specified",
 		},
 		{
 			name: "error_both_keys_specified",
 			flags: map[string]interface{}{
 				"app-id":     "123456",
-				"key":        "fixtures/test-private-key.test.pem",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
 				"base64-key": keyBase64,
 			},
 			setupMocks:    func() {},
-			expectedError: "only one of --key or --base64-key may be specified",
+			expectedError: "only one of --key, --base64-key, --key-agent, --pkcs11-key or --key-command may be specified",
+		},
+		{
+			name: "error_installation_id_and_owner_specified",
+			flags: map[string]interface{}{
+				"app-id":          "123456",
+				"installation-id": "12345",
+				"owner":           "octo-org",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
+			},
+			setupMocks:    func() {},
+			expectedError: "only one of --installation-id, --owner or --repository may be specified",
+		},
+		{
+			name: "error_repository_not_installed",
+			flags: map[string]interface{}{
+				"app-id":     "123456",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
+				"repository": "octo-org/octo-repo",
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/repos/octo-org/octo-repo/installation",
+					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+			},
+			expectedError: "failed retrieving installation ID for repository: the app is not installed on octo-org/octo-repo",
 		},
 		{
 			name: "error_invalid_key_file",
 			flags: map[string]interface{}{
 				"app-id": "123456",
-				"key":    "fixtures/nonexistent.pem",
+				"key":    []string{"fixtures/nonexistent.pem"},
 			},
 			setupMocks:    func() {},
 			expectedError: "unable to read key file",
@@ -189,20 +251,59 @@ func TestGenerate(t *testing.T) {
 			name: "error_installation_not_found",
 			flags: map[string]interface{}{
 				"app-id": "123456",
-				"key":    "fixtures/test-private-key.test.pem",
+				"key":    []string{"fixtures/test-private-key.test.pem"},
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=1",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
 			},
 			expectedError: "failed retrieving default installation ID: unexpected status code: 404",
 		},
+		{
+			name: "successful_scoped_token_generation",
+			flags: map[string]interface{}{
+				"app-id":          "123456",
+				"installation-id": "12345",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
+				"repositories":    []string{"gh-token"},
+				"permissions":     []string{"contents:read", "issues:write"},
+				"silent":          true,
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(201, string(tokenJSON)))
+			},
+			expectedError: "",
+		},
+		{
+			name: "error_unknown_permission",
+			flags: map[string]interface{}{
+				"app-id":          "123456",
+				"installation-id": "12345",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
+				"permissions":     []string{"contents:read", "everything:write"},
+			},
+			setupMocks:    func() {},
+			expectedError: "unknown permission \"everything\"",
+		},
+		{
+			name: "error_multiple_installations_without_selection",
+			flags: map[string]interface{}{
+				"app-id": "123456",
+				"key":    []string{"fixtures/test-private-key.test.pem"},
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
+					httpmock.NewStringResponder(200, `[{"id": 12345, "account": {"login": "octocat"}}, {"id": 67890, "account": {"login": "octo-org"}}]`))
+			},
+			expectedError: "failed retrieving default installation ID: found 2 matching installations",
+		},
 		{
 			name: "error_token_generation_fails",
 			flags: map[string]interface{}{
 				"app-id":          "123456",
 				"installation-id": "12345",
-				"key":             "fixtures/test-private-key.test.pem",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
 			},
 			setupMocks: func() {
 				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
@@ -245,10 +346,17 @@ func TestRetrieveDefaultInstallationID(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
 
+	multipleInstallations := `[
+		{"id": 12345, "account": {"login": "octocat", "type": "User"}},
+		{"id": 67890, "account": {"login": "octo-org", "type": "Organization"}},
+		{"id": 11111, "account": {"login": "other-org", "type": "Organization"}}
+	]`
+
 	tests := []struct {
 		name           string
 		hostname       string
 		jwt            string
+		criteria       installationCriteria
 		responseCode   int
 		responseBody   string
 		expectedResult string
@@ -263,6 +371,84 @@ func TestRetrieveDefaultInstallationID(t *testing.T) {
 			expectedResult: "12345",
 			expectedError:  "",
 		},
+		{
+			name:           "no_installations",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			responseCode:   200,
+			responseBody:   `[]`,
+			expectedResult: "",
+			expectedError:  "the app has no installations",
+		},
+		{
+			name:           "multiple_installations_are_ambiguous",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "",
+			expectedError:  "found 3 matching installations, select one with --installation-id, --owner, --account-login or --first:\n  octocat (ID 12345)\n  octo-org (ID 67890)\n  other-org (ID 11111)",
+		},
+		{
+			name:           "multiple_installations_with_first",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{First: true},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "12345",
+			expectedError:  "",
+		},
+		{
+			name:           "select_by_account_type",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{AccountType: "user"},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "12345",
+			expectedError:  "",
+		},
+		{
+			name:           "select_by_account_type_still_ambiguous",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{AccountType: "Organization"},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "",
+			expectedError:  "found 2 matching installations",
+		},
+		{
+			name:           "select_by_account_login",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{AccountLogin: "Octo-Org"},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "67890",
+			expectedError:  "",
+		},
+		{
+			name:           "no_match_for_account_login",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{AccountLogin: "missing"},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "",
+			expectedError:  "none of the 3 installations of the app match the given account type or login",
+		},
+		{
+			name:           "invalid_account_type",
+			hostname:       "api.github.com",
+			jwt:            "test.jwt.token",
+			criteria:       installationCriteria{AccountType: "Enterprise"},
+			responseCode:   200,
+			responseBody:   multipleInstallations,
+			expectedResult: "",
+			expectedError:  "invalid account type \"Enterprise\"",
+		},
 		{
 			name:           "not_found",
 			hostname:       "api.github.com",
@@ -287,11 +473,11 @@ func TestRetrieveDefaultInstallationID(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			httpmock.Reset()
 
-			endpoint := fmt.Sprintf("https://%s/app/installations?per_page=1", tt.hostname)
+			endpoint := fmt.Sprintf("https://%s/app/installations?per_page=100&page=1", tt.hostname)
 			httpmock.RegisterResponder("GET", endpoint,
 				httpmock.NewStringResponder(tt.responseCode, tt.responseBody))
 
-			result, err := retrieveDefaultInstallationID(tt.hostname, tt.jwt)
+			result, err := retrieveDefaultInstallationID(context.Background(), tt.hostname, tt.jwt, tt.criteria)
 
 			if tt.expectedError != "" {
 				assert.Error(t, err)
@@ -365,7 +551,7 @@ func TestGenerateToken(t *testing.T) {
 			httpmock.RegisterResponder("POST", endpoint,
 				httpmock.NewStringResponder(tt.responseCode, tt.responseBody))
 
-			result, err := generateToken(tt.hostname, tt.jwt, tt.installationID)
+			result, err := generateToken(context.Background(), tt.hostname, tt.jwt, tt.installationID, nil)
 
 			if tt.expectedError != "" {
 				assert.Error(t, err)
@@ -384,6 +570,67 @@ func TestGenerateToken(t *testing.T) {
 	}
 }
 
+func TestGenerateTokenRequestBody(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+
+	tests := []struct {
+		name         string
+		options      *github.InstallationTokenOptions
+		expectedBody string
+	}{
+		{
+			name:         "no_options_sends_empty_body",
+			options:      nil,
+			expectedBody: "",
+		},
+		{
+			name: "options_are_sent_as_json",
+			options: &github.InstallationTokenOptions{
+				Repositories:  []string{"gh-token"},
+				RepositoryIDs: []int64{42},
+				Permissions: &github.InstallationPermissions{
+					Contents: github.String("read"),
+				},
+			},
+			expectedBody: `{"repository_ids":[42],"repositories":["gh-token"],"permissions":{"contents":"read"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+
+			httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+				func(req *http.Request) (*http.Response, error) {
+					var body []byte
+					if req.Body != nil {
+						body, _ = io.ReadAll(req.Body)
+					}
+					if tt.expectedBody == "" {
+						assert.Empty(t, body)
+					} else {
+						assert.JSONEq(t, tt.expectedBody, string(body))
+						assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+					}
+
+					return httpmock.NewStringResponse(201, string(tokenJSON)), nil
+				})
+
+			result, err := generateToken(context.Background(), "api.github.com", "test.jwt.token", "12345", tt.options)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "ghs_test_token_123", *result.Token)
+		})
+	}
+}
+
 func TestGenerateAdvancedCases(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -399,7 +646,7 @@ func TestGenerateAdvancedCases(t *testing.T) {
 			setupTest: func() *cli.Context {
 				return createTestContext(map[string]interface{}{
 					"app-id":     "123456",
-					"key":        "fixtures/test-private-key.test.pem",
+					"key":        []string{"fixtures/test-private-key.test.pem"},
 					"jwt-expiry": 0, // Below minimum, should be adjusted to 10
 					"jwt":        true,
 					"silent":     true,
@@ -413,7 +660,7 @@ func TestGenerateAdvancedCases(t *testing.T) {
 			setupTest: func() *cli.Context {
 				return createTestContext(map[string]interface{}{
 					"app-id":     "123456",
-					"key":        "fixtures/test-private-key.test.pem",
+					"key":        []string{"fixtures/test-private-key.test.pem"},
 					"jwt-expiry": 15, // Above maximum, should be adjusted to 10
 					"jwt":        true,
 					"silent":     true,
@@ -428,7 +675,7 @@ func TestGenerateAdvancedCases(t *testing.T) {
 				return createTestContext(map[string]interface{}{
 					"app-id":          "123456",
 					"installation-id": "12345",
-					"key":             "fixtures/test-private-key.test.pem",
+					"key":             []string{"fixtures/test-private-key.test.pem"},
 					"hostname":        "github.company.com", // Without /api/v3
 					"silent":          true,
 				})
@@ -444,13 +691,35 @@ func TestGenerateAdvancedCases(t *testing.T) {
 			},
 			expectedError: "",
 		},
+		{
+			name: "hostname_ghe_com_tenant",
+			setupTest: func() *cli.Context {
+				return createTestContext(map[string]interface{}{
+					"app-id":          "123456",
+					"installation-id": "12345",
+					"key":             []string{"fixtures/test-private-key.test.pem"},
+					"hostname":        "octocorp.ghe.com", // Served by api.octocorp.ghe.com
+					"silent":          true,
+				})
+			},
+			setupMocks: func() {
+				tokenResponse := &github.InstallationToken{
+					Token:     github.String("ghs_test_token_123"),
+					ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+				}
+				tokenJSON, _ := json.Marshal(tokenResponse)
+				httpmock.RegisterResponder("POST", "https://api.octocorp.ghe.com/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(201, string(tokenJSON)))
+			},
+			expectedError: "",
+		},
 		{
 			name: "hostname_with_api_path_already_included",
 			setupTest: func() *cli.Context {
 				return createTestContext(map[string]interface{}{
 					"app-id":          "123456",
 					"installation-id": "12345",
-					"key":             "fixtures/test-private-key.test.pem",
+					"key":             []string{"fixtures/test-private-key.test.pem"},
 					"hostname":        "github.company.com/api/v3", // Already has /api/v3
 					"silent":          true,
 				})
@@ -486,6 +755,173 @@ func TestGenerateAdvancedCases(t *testing.T) {
 	}
 }
 
+func TestGenerateFromGit(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keyPath, err := filepath.Abs("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+
+	tests := []struct {
+		name          string
+		remoteURL     string
+		flags         map[string]interface{}
+		setupMocks    func()
+		expectedBody  string
+		expectedError string
+	}{
+		{
+			name:      "infers_repository_installation",
+			remoteURL: "git@github.com:octo-org/octo-repo.git",
+			flags:     map[string]interface{}{},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/repos/octo-org/octo-repo/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+			},
+			expectedBody: "",
+		},
+		{
+			name:      "infers_enterprise_server_hostname",
+			remoteURL: "https://github.example.com/octo-org/octo-repo.git",
+			flags:     map[string]interface{}{},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://github.example.com/api/v3/repos/octo-org/octo-repo/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+				httpmock.RegisterResponder("POST", "https://github.example.com/api/v3/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(201, string(tokenJSON)))
+			},
+			expectedBody: "",
+		},
+		{
+			name:      "scopes_token_to_inferred_repository",
+			remoteURL: "git@github.com:octo-org/octo-repo.git",
+			flags: map[string]interface{}{
+				"scope-repository": true,
+			},
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/repos/octo-org/octo-repo/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+			},
+			expectedBody: `{"repositories":["octo-repo"]}`,
+		},
+		{
+			name:      "explicit_installation_id_takes_precedence",
+			remoteURL: "git@github.com:octo-org/octo-repo.git",
+			flags: map[string]interface{}{
+				"installation-id": "12345",
+			},
+			setupMocks:   func() {},
+			expectedBody: "",
+		},
+		{
+			name:      "missing_remote",
+			remoteURL: "git@github.com:octo-org/octo-repo.git",
+			flags: map[string]interface{}{
+				"git-remote": "upstream",
+			},
+			setupMocks:    func() {},
+			expectedError: "unable to read URL of git remote \"upstream\"",
+		},
+		{
+			name:          "remote_is_not_a_repository",
+			remoteURL:     "/srv/git/octo-repo.git",
+			flags:         map[string]interface{}{},
+			setupMocks:    func() {},
+			expectedError: "unable to parse git remote URL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			tt.setupMocks()
+			httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+				func(req *http.Request) (*http.Response, error) {
+					var body []byte
+					if req.Body != nil {
+						body, _ = io.ReadAll(req.Body)
+					}
+					if tt.expectedBody == "" {
+						assert.Empty(t, body)
+					} else {
+						assert.JSONEq(t, tt.expectedBody, string(body))
+					}
+
+					return httpmock.NewStringResponse(201, string(tokenJSON)), nil
+				})
+
+			t.Chdir(initGitRepository(t, tt.remoteURL))
+
+			flags := map[string]interface{}{
+				"app-id":   "123456",
+				"key":      []string{keyPath},
+				"from-git": true,
+				"silent":   true,
+			}
+			for k, v := range tt.flags {
+				flags[k] = v
+			}
+			err := Generate(createTestContext(flags))
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+
+				info := httpmock.GetCallCountInfo()
+				assert.Greater(t, len(info), 0, "Expected HTTP calls to be made")
+			}
+		})
+	}
+}
+
+func TestGenerateWithCache(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		httpmock.NewStringResponder(201, string(tokenJSON)))
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/67890/access_tokens",
+		httpmock.NewStringResponder(201, string(tokenJSON)))
+
+	cacheDir := t.TempDir()
+	run := func(installationID string, permissions []string) {
+		flags := map[string]interface{}{
+			"app-id":          "123456",
+			"installation-id": installationID,
+			"key":             []string{"fixtures/test-private-key.test.pem"},
+			"cache":           true,
+			"cache-dir":       cacheDir,
+			"silent":          true,
+		}
+		if permissions != nil {
+			flags["permissions"] = permissions
+		}
+		assert.NoError(t, Generate(createTestContext(flags)))
+	}
+
+	run("12345", nil)
+	run("12345", nil)
+	run("12345", []string{"contents:read"})
+	run("67890", nil)
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 2, info["POST https://api.github.com/app/installations/12345/access_tokens"])
+	assert.Equal(t, 1, info["POST https://api.github.com/app/installations/67890/access_tokens"])
+}
+
 // TestGenerateWithOutputFormats tests different output formats
 func TestGenerateWithOutputFormats(t *testing.T) {
 	httpmock.Activate()
@@ -507,7 +943,7 @@ func TestGenerateWithOutputFormats(t *testing.T) {
 			flags: map[string]interface{}{
 				"app-id":          "123456",
 				"installation-id": "12345",
-				"key":             "fixtures/test-private-key.test.pem",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
 				"hostname":        "api.github.com",
 				"jwt-expiry":      10,
 				"silent":          false, // To test JSON output
@@ -522,7 +958,7 @@ func TestGenerateWithOutputFormats(t *testing.T) {
 			flags: map[string]interface{}{
 				"app-id":          "123456",
 				"installation-id": "12345",
-				"key":             "fixtures/test-private-key.test.pem",
+				"key":             []string{"fixtures/test-private-key.test.pem"},
 				"hostname":        "api.github.com",
 				"jwt-expiry":      10,
 				"token-only":      true,
@@ -537,7 +973,7 @@ func TestGenerateWithOutputFormats(t *testing.T) {
 			name: "jwt_output_format",
 			flags: map[string]interface{}{
 				"app-id":     "123456",
-				"key":        "fixtures/test-private-key.test.pem",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
 				"jwt":        true,
 				"jwt-expiry": 10,
 				"silent":     false, // To test JWT output
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// gitRemote is a GitHub repository parsed from a git remote URL
+type gitRemote struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+// scpLikeURL matches the scp-like syntax used by SSH remotes, for example
+// git@github.com:owner/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// readGitRemoteURL returns the URL of a git remote for the working tree in
+// dir, or the current working directory if dir is empty
+func readGitRemoteURL(dir, remote string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", remote)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("unable to read URL of git remote %q: %s", remote, message)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// parseRemoteURL parses SSH, scp-like and HTTPS git remote URLs
+func parseRemoteURL(remoteURL string) (*gitRemote, error) {
+	remoteURL = strings.TrimSpace(remoteURL)
+
+	var host, path string
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse git remote URL %q: %w", remoteURL, err)
+		}
+
+		switch u.Scheme {
+		case "https", "http":
+			// Keep a custom port since the API is served on the same one
+			host = u.Host
+		case "ssh", "git+ssh", "ssh+git", "git":
+			// The port of an SSH or git remote is not the port of the API
+			host = u.Hostname()
+		default:
+			return nil, fmt.Errorf("unsupported git remote URL scheme %q", u.Scheme)
+		}
+		path = u.Path
+	} else if matches := scpLikeURL.FindStringSubmatch(remoteURL); matches != nil {
+		host = matches[1]
+		path = matches[2]
+	} else {
+		return nil, fmt.Errorf("unable to parse git remote URL %q", remoteURL)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	owner, name, found := strings.Cut(path, "/")
+	if host == "" || !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("git remote URL %q does not point to a repository in the owner/name format", remoteURL)
+	}
+
+	return &gitRemote{
+		Host:  strings.ToLower(host),
+		Owner: owner,
+		Name:  name,
+	}, nil
+}
+
+// apiHostname returns the hostname of the API serving the remote's host
+func (r *gitRemote) apiHostname() string {
+	switch r.Host {
+	case "github.com", "www.github.com", "ssh.github.com":
+		return "api.github.com"
+	default:
+		return r.Host
+	}
+}
+
+// fullName returns the repository in the owner/name format
+func (r *gitRemote) fullName() string {
+	return fmt.Sprintf("%s/%s", r.Owner, r.Name)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Link-/gh-token/ghtoken"
+)
+
+// apiBaseURL returns the base URL of the API of the GitHub instance given by
+// --hostname, which may be a host or a URL:
+//
+//   - github.com and api.github.com are served by https://api.github.com
+//   - GHE.com tenants such as octocorp.ghe.com by https://api.octocorp.ghe.com
+//   - GitHub Enterprise Server hosts such as github.example.com by
+//     https://github.example.com/api/v3
+//
+// URLs may use http, e.g. for local test servers, and a port. The path of a
+// URL is kept when it is given, the one of a host defaults to /api/v3.
+// Resolving a base URL returns it unchanged.
+func apiBaseURL(hostname string) (string, error) {
+	value := strings.TrimSpace(hostname)
+	if value == "" {
+		return ghtoken.DefaultBaseURL, nil
+	}
+
+	explicitScheme := strings.Contains(value, "://")
+	if !explicitScheme {
+		value = "https://" + value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("invalid hostname %q, expected a host such as github.example.com or an http(s) URL", hostname)
+	}
+
+	host := strings.ToLower(u.Host)
+	name := strings.ToLower(u.Hostname())
+	switch {
+	case name == "github.com" || name == "www.github.com" || name == "api.github.com":
+		return u.Scheme + "://api.github.com", nil
+	case strings.HasSuffix(name, ".ghe.com"):
+		if !strings.HasPrefix(name, "api.") {
+			host = "api." + host
+		}
+		return u.Scheme + "://" + host, nil
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/api/v3"):
+	case strings.HasSuffix(path, "/api"):
+		path += "/v3"
+	case path != "" && explicitScheme:
+	default:
+		path += "/api/v3"
+	}
+
+	return u.Scheme + "://" + host + path, nil
+}
+
+// gitHost returns the scheme and host git uses for the repositories of the
+// GitHub instance serving the API at baseURL
+func gitHost(baseURL string) (string, string) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", ""
+	}
+
+	host := u.Host
+	switch {
+	case host == "api.github.com":
+		host = "github.com"
+	case strings.HasSuffix(u.Hostname(), ".ghe.com"):
+		host = strings.TrimPrefix(host, "api.")
+	}
+
+	return u.Scheme, host
+}
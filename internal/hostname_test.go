@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIBaseURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		hostname    string
+		expectedURL string
+		expectedErr string
+	}{
+		{name: "empty", hostname: "", expectedURL: "https://api.github.com"},
+		{name: "github.com API", hostname: "api.github.com", expectedURL: "https://api.github.com"},
+		{name: "github.com", hostname: "github.com", expectedURL: "https://api.github.com"},
+		{name: "github.com URL", hostname: "https://api.github.com/", expectedURL: "https://api.github.com"},
+		{name: "github.com case insensitive", hostname: "API.GITHUB.COM", expectedURL: "https://api.github.com"},
+		{name: "GHE.com tenant", hostname: "octocorp.ghe.com", expectedURL: "https://api.octocorp.ghe.com"},
+		{name: "GHE.com API", hostname: "api.octocorp.ghe.com", expectedURL: "https://api.octocorp.ghe.com"},
+		{name: "GHE.com URL", hostname: "https://octocorp.ghe.com/", expectedURL: "https://api.octocorp.ghe.com"},
+		{name: "GHES host", hostname: "github.example.com", expectedURL: "https://github.example.com/api/v3"},
+		{name: "GHES host with API path", hostname: "github.example.com/api/v3", expectedURL: "https://github.example.com/api/v3"},
+		{name: "GHES host with trailing slash", hostname: "github.example.com/api/v3/", expectedURL: "https://github.example.com/api/v3"},
+		{name: "GHES host with port", hostname: "GitHub.Example.com:8443", expectedURL: "https://github.example.com:8443/api/v3"},
+		{name: "GHES URL", hostname: "https://github.example.com/", expectedURL: "https://github.example.com/api/v3"},
+		{name: "GHES URL with API path", hostname: "https://github.example.com/api", expectedURL: "https://github.example.com/api/v3"},
+		{name: "http test server", hostname: "http://localhost:8080", expectedURL: "http://localhost:8080/api/v3"},
+		{name: "http test server with path", hostname: "http://127.0.0.1:8080/mock", expectedURL: "http://127.0.0.1:8080/mock"},
+		{name: "unsupported scheme", hostname: "ssh://github.example.com", expectedErr: `invalid hostname "ssh://github.example.com", expected a host such as github.example.com or an http(s) URL`},
+		{name: "missing host", hostname: "https://", expectedErr: `invalid hostname "https://", expected a host such as github.example.com or an http(s) URL`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, err := apiBaseURL(tt.hostname)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedURL, baseURL)
+
+			// Resolving a base URL again leaves it unchanged
+			again, err := apiBaseURL(baseURL)
+			assert.NoError(t, err)
+			assert.Equal(t, baseURL, again)
+		})
+	}
+}
+
+func TestGitHost(t *testing.T) {
+	tests := []struct {
+		baseURL        string
+		expectedScheme string
+		expectedHost   string
+	}{
+		{baseURL: "https://api.github.com", expectedScheme: "https", expectedHost: "github.com"},
+		{baseURL: "https://api.octocorp.ghe.com", expectedScheme: "https", expectedHost: "octocorp.ghe.com"},
+		{baseURL: "https://github.example.com:8443/api/v3", expectedScheme: "https", expectedHost: "github.example.com:8443"},
+		{baseURL: "http://localhost:8080/api/v3", expectedScheme: "http", expectedHost: "localhost:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			scheme, host := gitHost(tt.baseURL)
+			assert.Equal(t, tt.expectedScheme, scheme)
+			assert.Equal(t, tt.expectedHost, host)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// httpClient sends the API requests of all commands. Its default transport
+// is http.DefaultTransport, which honors HTTPS_PROXY and NO_PROXY.
+var httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+const (
+	defaultHTTPTimeout    = 60 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+)
+
+// httpOptions configures the HTTP client
+type httpOptions struct {
+	caCert         string
+	clientCert     string
+	clientKey      string
+	connectTimeout time.Duration
+	timeout        time.Duration
+}
+
+// ConfigureHTTPClient configures the HTTP client shared by the commands from
+// the --ca-cert, --client-cert, --client-key, --connect-timeout and
+// --timeout flags
+func ConfigureHTTPClient(c *cli.Context) error {
+	client, err := newHTTPClient(httpOptions{
+		caCert:         c.String("ca-cert"),
+		clientCert:     c.String("client-cert"),
+		clientKey:      c.String("client-key"),
+		connectTimeout: c.Duration("connect-timeout"),
+		timeout:        c.Duration("timeout"),
+	})
+	if err != nil {
+		return err
+	}
+
+	httpClient = client
+
+	return nil
+}
+
+// newHTTPClient returns a client reusing its connections, which goes through
+// the proxy of the environment
+func newHTTPClient(options httpOptions) (*http.Client, error) {
+	if options.timeout < 0 || options.connectTimeout < 0 {
+		return nil, fmt.Errorf("--timeout and --connect-timeout may not be negative")
+	}
+
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep using http.DefaultTransport when nothing is customized, which also
+	// lets tests replace it
+	if tlsConfig == nil && options.connectTimeout == 0 {
+		return &http.Client{Timeout: options.timeout}, nil
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+
+	if options.connectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: options.connectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = options.connectTimeout
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, Timeout: options.timeout}, nil
+}
+
+// tlsConfig returns the TLS configuration trusting --ca-cert in addition to
+// the system CAs and presenting --client-cert, or nil when neither is set
+func (options httpOptions) tlsConfig() (*tls.Config, error) {
+	if options.caCert == "" && options.clientCert == "" && options.clientKey == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if options.caCert != "" {
+		pem, err := os.ReadFile(options.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificate: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", options.caCert)
+		}
+		config.RootCAs = pool
+	}
+
+	if (options.clientCert == "") != (options.clientKey == "") {
+		return nil, fmt.Errorf("--client-cert and --client-key must be specified together")
+	}
+
+	if options.clientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(options.clientCert, options.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,37 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// httpFlags returns the CLI flags configuring the HTTP client of the commands
+// sending API requests
+func httpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "ca-cert",
+			Usage:    "Path to a PEM bundle of CA certificates to trust in addition to the system ones, e.g. the internal CA of GitHub Enterprise Server",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "client-cert",
+			Usage:    "Path to a PEM client certificate presented to GitHub Enterprise Server or the proxy in front of it, requires --client-key",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "client-key",
+			Usage:    "Path to the PEM private key of --client-cert",
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "connect-timeout",
+			Usage:    "Maximum time to establish a connection to the API, including the TLS handshake",
+			Required: false,
+			Value:    defaultConnectTimeout,
+		},
+		&cli.DurationFlag{
+			Name:     "timeout",
+			Usage:    "Maximum time for each API request, including reading the response",
+			Required: false,
+			Value:    defaultHTTPTimeout,
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key
+// and returns their paths and the certificate
+func writeClientCertificate(t *testing.T) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gh-token"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	assert.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certPath, keyPath, certificate
+}
+
+// writeServerCA writes the certificate of a TLS test server
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	clientCert, clientKey, certificate := writeClientCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certificate)
+	server.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caCert := writeServerCA(t, server)
+
+	mtlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mtlsServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	mtlsServer.StartTLS()
+	defer mtlsServer.Close()
+
+	tests := []struct {
+		name        string
+		options     httpOptions
+		url         string
+		expectedErr string
+	}{
+		{
+			name:    "trusts the CA certificate",
+			options: httpOptions{caCert: caCert},
+			url:     server.URL,
+		},
+		{
+			name:        "rejects unknown CAs",
+			options:     httpOptions{connectTimeout: time.Second},
+			url:         server.URL,
+			expectedErr: "certificate signed by unknown authority",
+		},
+		{
+			name:    "presents the client certificate",
+			options: httpOptions{caCert: writeServerCA(t, mtlsServer), clientCert: clientCert, clientKey: clientKey},
+			url:     mtlsServer.URL,
+		},
+		{
+			name:        "requires the client certificate",
+			options:     httpOptions{caCert: writeServerCA(t, mtlsServer)},
+			url:         mtlsServer.URL,
+			expectedErr: "certificate required",
+		},
+		{
+			name:        "times out",
+			options:     httpOptions{caCert: caCert, timeout: 100 * time.Millisecond},
+			url:         server.URL + "/slow",
+			expectedErr: "Client.Timeout exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newHTTPClient(tt.options)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			response, err := client.Get(tt.url)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, response.StatusCode)
+			response.Body.Close()
+		})
+	}
+}
+
+func TestNewHTTPClientErrors(t *testing.T) {
+	clientCert, clientKey, _ := writeClientCertificate(t)
+
+	tests := []struct {
+		name        string
+		options     httpOptions
+		expectedErr string
+	}{
+		{
+			name:        "missing CA certificate",
+			options:     httpOptions{caCert: "fixtures/missing.pem"},
+			expectedErr: "unable to read CA certificate: open fixtures/missing.pem: no such file or directory",
+		},
+		{
+			name:        "CA certificate without certificate",
+			options:     httpOptions{caCert: clientKey},
+			expectedErr: "no PEM certificate found in " + clientKey,
+		},
+		{
+			name:        "client certificate without key",
+			options:     httpOptions{clientCert: clientCert},
+			expectedErr: "--client-cert and --client-key must be specified together",
+		},
+		{
+			name:        "mismatched client key",
+			options:     httpOptions{clientCert: clientCert, clientKey: "fixtures/test-private-key.test.pem"},
+			expectedErr: "unable to load client certificate: tls: private key type does not match public key type",
+		},
+		{
+			name:        "negative timeout",
+			options:     httpOptions{timeout: -time.Second},
+			expectedErr: "--timeout and --connect-timeout may not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newHTTPClient(tt.options)
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -1,34 +1,32 @@
 package internal
 
-import "github.com/urfave/cli/v2"
+import (
+	"slices"
+
+	"github.com/urfave/cli/v2"
+)
 
 // InstallationsFlags returns the CLI flags for the generate command
 func InstallationsFlags() []cli.Flag {
-	return []cli.Flag{
+	return withEnvVars(slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:     "app-id",
 			Usage:    "GitHub App ID",
-			Required: true,
-			Aliases:  []string{"i", "app_id"},
-		},
-		&cli.StringFlag{
-			Name:     "key",
-			Usage:    "Path to private key",
 			Required: false,
-			Aliases:  []string{"k"},
+			Aliases:  []string{"i", "app_id"},
 		},
 		&cli.StringFlag{
-			Name:     "base64-key",
-			Usage:    "A base64 encoded private key",
+			Name:     "profile",
+			Usage:    "Name of the configuration file profile providing the defaults of the other flags",
 			Required: false,
-			Aliases:  []string{"b", "base64_key"},
+			EnvVars:  []string{"GH_TOKEN_PROFILE"},
 		},
 		&cli.StringFlag{
 			Name:     "hostname",
-			Usage:    "GitHub Enterprise Server API endpoint, example: github.example.com",
+			Usage:    "GitHub Enterprise Server or GHE.com host, or API URL, example: github.example.com, octocorp.ghe.com or http://localhost:8080/api/v3",
 			Required: false,
 			Aliases:  []string{"o"},
 			Value:    "api.github.com",
 		},
-	}
+	}, keyFlags(), httpFlags()))
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"flag"
@@ -23,7 +24,7 @@ func createTestContextForInstallations(flags map[string]interface{}) *cli.Contex
 	// Set default values
 	defaults := map[string]interface{}{
 		"app-id":     "",
-		"key":        "",
+		"key":        []string{},
 		"base64-key": "",
 		"hostname":   "api.github.com",
 	}
@@ -42,6 +43,8 @@ func createTestContextForInstallations(flags map[string]interface{}) *cli.Contex
 			set.Bool(key, v, "")
 		case int:
 			set.Int(key, v, "")
+		case []string:
+			set.Var(cli.NewStringSlice(v...), key, "")
 		}
 	}
 
@@ -92,11 +95,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_list_installations_with_key_file",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(singleInstallationJSON)))
 			},
 			expectedError: "",
@@ -109,7 +112,7 @@ func TestInstallations(t *testing.T) {
 				"hostname":   "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(singleInstallationJSON)))
 			},
 			expectedError: "",
@@ -118,11 +121,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_list_multiple_installations",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(multipleInstallationsJSON)))
 			},
 			expectedError: "",
@@ -131,11 +134,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_empty_installations_list",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(emptyInstallationsJSON)))
 			},
 			expectedError: "",
@@ -144,11 +147,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_with_custom_hostname_without_api_path",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "github.company.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(singleInstallationJSON)))
 			},
 			expectedError: "",
@@ -157,11 +160,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_with_custom_hostname_with_api_path",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "github.company.com/api/v3",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(singleInstallationJSON)))
 			},
 			expectedError: "",
@@ -170,11 +173,11 @@ func TestInstallations(t *testing.T) {
 			name: "successful_with_mixed_case_hostname",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "GitHub.Company.COM",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(singleInstallationJSON)))
 			},
 			expectedError: "",
@@ -185,23 +188,23 @@ func TestInstallations(t *testing.T) {
 				"app-id": "123456",
 			},
 			setupMocks:    func() {},
-			expectedError: "either --key or --base64-key must be specified",
+			expectedError: "either --key, --base64-key, --key-agent, --pkcs11-key or --key-command must be specified",
 		},
 		{
 			name: "error_both_keys_specified",
 			flags: map[string]interface{}{
 				"app-id":     "123456",
-				"key":        "fixtures/test-private-key.test.pem",
+				"key":        []string{"fixtures/test-private-key.test.pem"},
 				"base64-key": keyBase64,
 			},
 			setupMocks:    func() {},
-			expectedError: "only one of --key or --base64-key may be specified",
+			expectedError: "only one of --key, --base64-key, --key-agent, --pkcs11-key or --key-command may be specified",
 		},
 		{
 			name: "error_invalid_key_file",
 			flags: map[string]interface{}{
 				"app-id": "123456",
-				"key":    "fixtures/nonexistent.pem",
+				"key":    []string{"fixtures/nonexistent.pem"},
 			},
 			setupMocks:    func() {},
 			expectedError: "unable to read key file",
@@ -219,11 +222,11 @@ func TestInstallations(t *testing.T) {
 			name: "error_http_request_fails",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewErrorResponder(fmt.Errorf("network error")))
 			},
 			expectedError: "failed listing installations",
@@ -232,11 +235,11 @@ func TestInstallations(t *testing.T) {
 			name: "error_http_status_not_200",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
 			},
 			expectedError: "failed listing installations: unexpected status code: 404",
@@ -245,11 +248,11 @@ func TestInstallations(t *testing.T) {
 			name: "error_invalid_json_response",
 			flags: map[string]interface{}{
 				"app-id":   "123456",
-				"key":      "fixtures/test-private-key.test.pem",
+				"key":      []string{"fixtures/test-private-key.test.pem"},
 				"hostname": "api.github.com",
 			},
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, "invalid json"))
 			},
 			expectedError: "failed listing installations: unable to unmarshal response body",
@@ -325,7 +328,7 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(firstPageJSON)))
 			},
 			expectedCount: 2,
@@ -336,9 +339,9 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
-					httpmock.NewStringResponder(200, string(fullPageJSON)))
 				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
+					httpmock.NewStringResponder(200, string(fullPageJSON)))
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=2",
 					httpmock.NewStringResponder(200, string(secondPageJSON)))
 			},
 			expectedCount: 101,
@@ -349,7 +352,7 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(emptyResponseJSON)))
 			},
 			expectedCount: 0,
@@ -360,7 +363,7 @@ func TestListInstallations(t *testing.T) {
 			hostname: "github.company.com/api/v3",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, string(firstPageJSON)))
 			},
 			expectedCount: 2,
@@ -371,18 +374,18 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewErrorResponder(fmt.Errorf("network error")))
 			},
 			expectedCount: 0,
-			expectedError: "unable to POST to",
+			expectedError: "unable to GET",
 		},
 		{
 			name:     "error_status_not_200",
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(401, `{"message": "Unauthorized"}`))
 			},
 			expectedCount: 0,
@@ -393,7 +396,7 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 					httpmock.NewStringResponder(200, "invalid json"))
 			},
 			expectedCount: 0,
@@ -404,9 +407,9 @@ func TestListInstallations(t *testing.T) {
 			hostname: "api.github.com",
 			jwt:      "test.jwt.token",
 			setupMocks: func() {
-				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
-					httpmock.NewStringResponder(200, string(fullPageJSON)))
 				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
+					httpmock.NewStringResponder(200, string(fullPageJSON)))
+				httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=2",
 					httpmock.NewStringResponder(500, `{"message": "Internal Server Error"}`))
 			},
 			expectedCount: 0,
@@ -421,7 +424,7 @@ func TestListInstallations(t *testing.T) {
 			tt.setupMocks()
 
 			// Execute the function
-			result, err := listInstallations(tt.hostname, tt.jwt)
+			result, err := listInstallations(context.Background(), tt.hostname, tt.jwt)
 
 			// Assert results
 			if tt.expectedError != "" {
@@ -440,7 +443,7 @@ func TestListInstallations(t *testing.T) {
 				// Verify request headers for the first request
 				if tt.expectedCount > 0 {
 					// Check that the Authorization header was set correctly
-					endpoint := fmt.Sprintf("https://%s/app/installations?per_page=100&page=0", tt.hostname)
+					endpoint := fmt.Sprintf("https://%s/app/installations?per_page=100&page=1", tt.hostname)
 					assert.Equal(t, 1, info[fmt.Sprintf("GET %s", endpoint)], "Expected exactly one call to first page")
 				}
 			}
@@ -472,7 +475,7 @@ func TestInstallationsPaginationBehavior(t *testing.T) {
 	}
 	page1JSON, _ := json.Marshal(page1Response)
 
-	// Page 2 has less than 100 results, should stop pagination
+	// Page 3 has less than 100 results, should stop pagination
 	page2Response := make([]github.Installation, 50)
 	for i := 0; i < 50; i++ {
 		page2Response[i] = github.Installation{
@@ -485,14 +488,14 @@ func TestInstallationsPaginationBehavior(t *testing.T) {
 	t.Run("pagination_stops_when_less_than_100_results", func(t *testing.T) {
 		httpmock.Reset()
 
-		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
-			httpmock.NewStringResponder(200, string(page0JSON)))
 		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
-			httpmock.NewStringResponder(200, string(page1JSON)))
+			httpmock.NewStringResponder(200, string(page0JSON)))
 		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=2",
+			httpmock.NewStringResponder(200, string(page1JSON)))
+		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=3",
 			httpmock.NewStringResponder(200, string(page2JSON)))
 
-		result, err := listInstallations("api.github.com", "test.jwt.token")
+		result, err := listInstallations(context.Background(), "api.github.com", "test.jwt.token")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -500,11 +503,11 @@ func TestInstallationsPaginationBehavior(t *testing.T) {
 
 		// Verify all three pages were called
 		info := httpmock.GetCallCountInfo()
-		assert.Equal(t, 1, info["GET https://api.github.com/app/installations?per_page=100&page=0"])
 		assert.Equal(t, 1, info["GET https://api.github.com/app/installations?per_page=100&page=1"])
 		assert.Equal(t, 1, info["GET https://api.github.com/app/installations?per_page=100&page=2"])
-		// Page 3 should not be called
-		assert.Equal(t, 0, info["GET https://api.github.com/app/installations?per_page=100&page=3"])
+		assert.Equal(t, 1, info["GET https://api.github.com/app/installations?per_page=100&page=3"])
+		// Page 4 should not be called
+		assert.Equal(t, 0, info["GET https://api.github.com/app/installations?per_page=100&page=4"])
 	})
 }
 
@@ -521,7 +524,7 @@ func TestInstallationsRequestHeaders(t *testing.T) {
 		httpmock.Reset()
 
 		// Use a custom responder to check headers
-		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=0",
+		httpmock.RegisterResponder("GET", "https://api.github.com/app/installations?per_page=100&page=1",
 			func(req *http.Request) (*http.Response, error) {
 				// Verify headers
 				assert.Equal(t, "Bearer test.jwt.token", req.Header.Get("Authorization"))
@@ -532,7 +535,7 @@ func TestInstallationsRequestHeaders(t *testing.T) {
 				return httpmock.NewStringResponse(200, string(installationJSON)), nil
 			})
 
-		result, err := listInstallations("api.github.com", "test.jwt.token")
+		result, err := listInstallations(context.Background(), "api.github.com", "test.jwt.token")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -1,53 +1,376 @@
 package internal
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
+	"bytes"
+	"crypto"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/Link-/gh-token/ghtoken"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 )
 
-func readKey(path string) (*rsa.PrivateKey, error) {
-	keyBytes, err := os.ReadFile(path)
+// pkcs11Config locates a private key in a PKCS#11 token
+type pkcs11Config struct {
+	module string
+	token  string
+	key    string
+	pin    string
+}
+
+// keyPassphrase returns the passphrase of an encrypted private key
+type keyPassphrase func() ([]byte, error)
+
+// appKey is one of the keys of an app, only loaded when it is needed
+type appKey struct {
+	name string
+	load func() (crypto.Signer, error)
+
+	once   sync.Once
+	signer crypto.Signer
+	err    error
+}
+
+func (k *appKey) get() (crypto.Signer, error) {
+	k.once.Do(func() {
+		k.signer, k.err = k.load()
+	})
+
+	return k.signer, k.err
+}
+
+// close releases the resources held by the signer, such as a PKCS#11
+// session, once it is no longer needed. The key cannot be loaded afterwards.
+func (k *appKey) close() {
+	k.once.Do(func() {
+		k.err = fmt.Errorf("key %s is closed", k.name)
+	})
+
+	if closer, ok := k.signer.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// appKeys are the keys of an app, tried in order while the API rejects the
+// JWTs they sign, as happens during a key rotation
+type appKeys []*appKey
+
+// close releases the resources held by the loaded keys
+func (keys appKeys) close() {
+	for _, key := range keys {
+		key.close()
+	}
+}
+
+// withJWT calls fn with a JWT signed by the first key, falling back to the
+// next keys while fn fails with a 401 status
+func (keys appKeys) withJWT(appID string, expiry int, fn func(jsonWebToken string) error) error {
+	var rejected []string
+	for i, key := range keys {
+		signer, err := key.get()
+		if err != nil {
+			return err
+		}
+
+		jsonWebToken, err := generateJWT(appID, expiry, signer)
+		if err != nil {
+			return fmt.Errorf("failed generating JWT: %w", err)
+		}
+
+		err = fn(jsonWebToken)
+		var statusErr *ghtoken.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == 401 && i < len(keys)-1 {
+			rejected = append(rejected, key.name)
+			continue
+		}
+
+		if err == nil && len(rejected) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: signed with the key %s since GitHub rejected %s, remove stale keys once the rotation is complete\n", key.name, strings.Join(rejected, ", "))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// loadAppKeys returns the app keys given through the --key, --key-env,
+// --key-fd, --key-dir, --base64-key, --key-agent, --pkcs11-key or
+// --key-command flags. Only the keys read from files or streams may be
+// combined for a rotation.
+func loadAppKeys(c *cli.Context) (appKeys, error) {
+	keyPaths, err := keyPathSources(c)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read key file: %w", err)
+		return nil, err
+	}
+
+	return keySources{
+		paths:   keyPaths,
+		base64:  c.String("base64-key"),
+		agent:   c.String("key-agent"),
+		command: c.String("key-command"),
+		pkcs11: pkcs11Config{
+			module: c.String("pkcs11-module"),
+			token:  c.String("pkcs11-token"),
+			key:    c.String("pkcs11-key"),
+			pin:    c.String("pkcs11-pin"),
+		},
+		passphrase: keyPassphraseSource(c),
+	}.appKeys()
+}
+
+// keySources holds the key sources of an app, of which only one may be set
+type keySources struct {
+	paths      []string
+	base64     string
+	agent      string
+	command    string
+	pkcs11     pkcs11Config
+	passphrase keyPassphrase
+}
+
+// appKeys returns the keys of the key source
+func (s keySources) appKeys() (appKeys, error) {
+	sources := 0
+	if len(s.paths) > 0 {
+		sources++
+	}
+	for _, source := range []string{s.base64, s.agent, s.pkcs11.key, s.command} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	if sources == 0 {
+		return nil, fmt.Errorf("either --key, --base64-key, --key-agent, --pkcs11-key or --key-command must be specified")
+	}
+
+	if sources > 1 {
+		return nil, fmt.Errorf("only one of --key, --base64-key, --key-agent, --pkcs11-key or --key-command may be specified")
+	}
+
+	switch {
+	case len(s.paths) > 0:
+		keys := make(appKeys, 0, len(s.paths))
+		for _, path := range s.paths {
+			keys = append(keys, &appKey{name: path, load: func() (crypto.Signer, error) {
+				return readKey(path, s.passphrase)
+			}})
+		}
+		return keys, nil
+	case s.base64 != "":
+		return appKeys{{name: "--base64-key", load: func() (crypto.Signer, error) {
+			return readKeyBase64(s.base64, s.passphrase)
+		}}}, nil
+	case s.agent != "":
+		return appKeys{{name: s.agent, load: func() (crypto.Signer, error) {
+			return newAgentSigner(s.agent)
+		}}}, nil
+	case s.command != "":
+		return appKeys{{name: s.command, load: func() (crypto.Signer, error) {
+			return newCommandSigner(s.command)
+		}}}, nil
+	default:
+		return appKeys{{name: s.pkcs11.key, load: func() (crypto.Signer, error) {
+			return newPKCS11Signer(s.pkcs11)
+		}}}, nil
+	}
+}
+
+// keyPathSources lists the key sources given through --key, in order,
+// followed by those of the --key-env and --key-fd shorthands and the keys
+// of --key-dir
+func keyPathSources(c *cli.Context) ([]string, error) {
+	sources := append([]string{}, c.StringSlice("key")...)
+	if name := c.String("key-env"); name != "" {
+		sources = append(sources, "env://"+name)
 	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+	if fd := c.String("key-fd"); fd != "" {
+		sources = append(sources, "fd://"+fd)
+	}
+
+	if dir := c.String("key-dir"); dir != "" {
+		paths, err := keyDirPaths(dir)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, paths...)
+	}
+
+	return sources, nil
+}
+
+// keyDirPaths lists the .pem files of dir by name in reverse order, so
+// that the newest of the keys downloaded from GitHub, whose names hold
+// their creation date, comes first
+func keyDirPaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse key from PEM to RSA format: %w", err)
+		return nil, fmt.Errorf("unable to read key directory: %w", err)
 	}
 
-	return key, nil
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".pem") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem key found in %s", dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	return paths, nil
+}
+
+// readKeySource reads a key from a file path, from stdin for "-", or from
+// an env://NAME, file://PATH or fd://N source
+func readKeySource(source string) ([]byte, error) {
+	if source == "-" {
+		keyBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key from stdin: %w", err)
+		}
+		return keyBytes, nil
+	}
+
+	scheme, value, found := strings.Cut(source, "://")
+	if !found {
+		scheme, value = "file", source
+	}
+
+	switch scheme {
+	case "env":
+		keyString, ok := os.LookupEnv(value)
+		if !ok || keyString == "" {
+			return nil, fmt.Errorf("unable to read key from environment: %s is not set", value)
+		}
+		return []byte(keyString), nil
+	case "file":
+		keyBytes, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key file: %w", err)
+		}
+		return keyBytes, nil
+	case "fd":
+		fd, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file descriptor %q", value)
+		}
+		file := os.NewFile(uintptr(fd), "fd://"+value)
+		defer func() {
+			_ = file.Close()
+		}()
+		keyBytes, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key from file descriptor %d: %w", fd, err)
+		}
+		return keyBytes, nil
+	default:
+		return nil, fmt.Errorf("unsupported key source %s://, expected env://, file:// or fd://", scheme)
+	}
+}
+
+// keyPassphraseSource returns the passphrase given through the
+// --key-passphrase or --key-passphrase-file flags, or else prompts for it on
+// the terminal
+func keyPassphraseSource(c *cli.Context) keyPassphrase {
+	if passphrase := c.String("key-passphrase"); passphrase != "" {
+		return func() ([]byte, error) {
+			return []byte(passphrase), nil
+		}
+	}
+
+	if path := c.String("key-passphrase-file"); path != "" {
+		return func() ([]byte, error) {
+			passphrase, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read key passphrase file: %w", err)
+			}
+			return bytes.TrimRight(passphrase, "\r\n"), nil
+		}
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+
+	return func() ([]byte, error) {
+		fmt.Fprint(os.Stderr, "Enter the passphrase of the app private key: ")
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key passphrase: %w", err)
+		}
+		return passphrase, nil
+	}
 }
 
-func readKeyBase64(keyBase64 string) (*rsa.PrivateKey, error) {
-	keyBytes, err := base64.StdEncoding.DecodeString(keyBase64)
+func readKey(source string, passphrase keyPassphrase) (crypto.Signer, error) {
+	keyBytes, err := readKeySource(source)
 	if err != nil {
-		return nil, fmt.Errorf("unable to decode key from base64: %w", err)
+		return nil, err
 	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+
+	return parseKey(keyBytes, passphrase)
+}
+
+// readKeyBase64 decodes a base64 encoded key, given either as is or through
+// one of the sources of readKeySource, which base64 never collides with
+func readKeyBase64(keyBase64 string, passphrase keyPassphrase) (crypto.Signer, error) {
+	if keyBase64 == "-" || strings.Contains(keyBase64, "://") {
+		encoded, err := readKeySource(keyBase64)
+		if err != nil {
+			return nil, err
+		}
+		keyBase64 = strings.TrimSpace(string(encoded))
+	}
+
+	key, repairs, err := ghtoken.RepairBase64PrivateKey(keyBase64, passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse key from PEM to RSA format: %w", err)
+		return nil, keyError(err)
 	}
+	warnKeyRepairs(repairs)
 
 	return key, nil
 }
 
-func generateJWT(appID string, expiry int, key *rsa.PrivateKey) (string, error) {
-	iat := jwt.NewNumericDate(time.Now().Add(-60 * time.Second))
-	exp := jwt.NewNumericDate(time.Now().Add(time.Duration(expiry) * 60 * time.Second))
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"iat": iat,
-		"exp": exp,
-		"iss": appID,
-	})
-	signedToken, err := token.SignedString(key)
+// parseKey parses a private key, repairing the usual mangling by secret
+// stores with a warning
+func parseKey(keyBytes []byte, passphrase keyPassphrase) (crypto.Signer, error) {
+	key, repairs, err := ghtoken.RepairPrivateKey(keyBytes, passphrase)
 	if err != nil {
-		return "", fmt.Errorf("unable to sign JWT: %w", err)
+		return nil, keyError(err)
 	}
+	warnKeyRepairs(repairs)
+
+	return key, nil
+}
+
+// keyError tells how to provide the passphrase of an encrypted key
+func keyError(err error) error {
+	if errors.Is(err, ghtoken.ErrPassphraseRequired) {
+		return fmt.Errorf("unable to parse key: the private key is encrypted, provide its passphrase with --key-passphrase, GH_TOKEN_KEY_PASSPHRASE or --key-passphrase-file")
+	}
+
+	return err
+}
+
+func warnKeyRepairs(repairs []string) {
+	if len(repairs) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: the private key was repaired (%s), consider storing it as downloaded from GitHub\n", strings.Join(repairs, ", "))
+	}
+}
 
-	return signedToken, nil
+func generateJWT(appID string, expiry int, signer crypto.Signer) (string, error) {
+	return ghtoken.NewAppCredentials(appID, signer).JWT(time.Duration(expiry) * time.Minute)
 }
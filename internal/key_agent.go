@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentSigner signs app JWTs with an RSA key held by the SSH agent listening
+// on socket, which produces RS256 signatures for rsa-sha2-256
+type agentSigner struct {
+	socket    string
+	key       ssh.PublicKey
+	publicKey *rsa.PublicKey
+}
+
+// newAgentSigner selects the key of the agent of SSH_AUTH_SOCK whose
+// SHA256 fingerprint or comment is selector
+func newAgentSigner(selector string) (*agentSigner, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("--key-agent requires a running ssh-agent, SSH_AUTH_SOCK is not set")
+	}
+
+	var keys []*agent.Key
+	err := withAgent(socket, func(client agent.ExtendedAgent) error {
+		var err error
+		keys, err = client.List()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the keys of the ssh-agent: %w", err)
+	}
+
+	for _, key := range keys {
+		if ssh.FingerprintSHA256(key) != selector && key.Comment != selector {
+			continue
+		}
+
+		if key.Type() != ssh.KeyAlgoRSA {
+			return nil, fmt.Errorf("the ssh-agent key %s is a %s key, app keys must be RSA keys", selector, key.Type())
+		}
+
+		publicKey, err := ssh.ParsePublicKey(key.Marshal())
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the ssh-agent key %s: %w", selector, err)
+		}
+		cryptoKey, ok := publicKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse the ssh-agent key %s", selector)
+		}
+		rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("the ssh-agent key %s is not an RSA key", selector)
+		}
+
+		return &agentSigner{socket: socket, key: publicKey, publicKey: rsaKey}, nil
+	}
+
+	return nil, fmt.Errorf("no key of the ssh-agent matches the fingerprint or comment %s", selector)
+}
+
+func (s *agentSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign is not supported since the agent hashes the message itself
+func (s *agentSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, fmt.Errorf("ssh-agent keys can only sign messages, not digests")
+}
+
+func (s *agentSigner) SignMessage(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("ssh-agent keys only sign with SHA-256")
+	}
+
+	var signature *ssh.Signature
+	err := withAgent(s.socket, func(client agent.ExtendedAgent) error {
+		var err error
+		signature, err = client.SignWithFlags(s.key, msg, agent.SignatureFlagRsaSha256)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("the ssh-agent refused to sign: %w", err)
+	}
+
+	if signature.Format != ssh.KeyAlgoRSASHA256 {
+		return nil, fmt.Errorf("the ssh-agent returned a %s signature instead of %s", signature.Format, ssh.KeyAlgoRSASHA256)
+	}
+
+	return signature.Blob, nil
+}
+
+// withAgent connects to the agent for the duration of fn, so that a
+// long-running process survives agent restarts
+func withAgent(socket string, fn func(agent.ExtendedAgent) error) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return fn(agent.NewClient(conn))
+}
@@ -0,0 +1,113 @@
+//go:build unix
+
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent serves keyring on a Unix socket pointed to by SSH_AUTH_SOCK
+func startTestAgent(t *testing.T, keyring agent.Agent) {
+	dir, err := os.MkdirTemp("", "agent")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+func TestAgentSigner(t *testing.T) {
+	key, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	keyring := agent.NewKeyring()
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key, Comment: "gh-token-test"}))
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: edKey, Comment: "ed25519"}))
+	startTestAgent(t, keyring)
+
+	publicKey, err := ssh.NewPublicKey(key.Public())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		selector      string
+		expectedError string
+	}{
+		{
+			name:     "comment",
+			selector: "gh-token-test",
+		},
+		{
+			name:     "fingerprint",
+			selector: ssh.FingerprintSHA256(publicKey),
+		},
+		{
+			name:          "non_rsa_key",
+			selector:      "ed25519",
+			expectedError: "app keys must be RSA keys",
+		},
+		{
+			name:          "unknown_key",
+			selector:      "nope",
+			expectedError: "no key of the ssh-agent matches the fingerprint or comment nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := newAgentSigner(tt.selector)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			signed, err := generateJWT("123456", 10, signer)
+			assert.NoError(t, err)
+
+			_, err = jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+				return key.Public(), nil
+			}, jwt.WithValidMethods([]string{"RS256"}))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAgentSignerWithoutAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, err := newAgentSigner("gh-token-test")
+
+	assert.EqualError(t, err, "--key-agent requires a running ssh-agent, SSH_AUTH_SOCK is not set")
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keyCommandRequest is written as JSON to the stdin of the --key-command
+// helper. The public_key operation asks for the PEM encoded public key, the
+// sign operation for the RS256 signature of the base64 encoded message.
+type keyCommandRequest struct {
+	Version   int    `json:"version"`
+	Operation string `json:"operation"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// keyCommandResponse is read as JSON from the stdout of the helper
+type keyCommandResponse struct {
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// commandSigner signs app JWTs by running an external helper, e.g. one
+// calling a cloud KMS
+type commandSigner struct {
+	command   []string
+	publicKey *rsa.PublicKey
+}
+
+// newCommandSigner asks the helper run by command for the public key of the
+// app key
+func newCommandSigner(command string) (*commandSigner, error) {
+	signer := &commandSigner{command: strings.Fields(command)}
+	if len(signer.command) == 0 {
+		return nil, fmt.Errorf("--key-command must not be empty")
+	}
+
+	response, err := signer.run(keyCommandRequest{Operation: "public_key"})
+	if err != nil {
+		return nil, err
+	}
+
+	signer.publicKey, err = parseRSAPublicKey([]byte(response.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("key command %s returned an invalid public key: %w", signer.command[0], err)
+	}
+
+	return signer, nil
+}
+
+func (s *commandSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign is not supported since the helper is handed the message to sign
+func (s *commandSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, fmt.Errorf("key commands can only sign messages, not digests")
+}
+
+func (s *commandSigner) SignMessage(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("key commands only sign with SHA-256")
+	}
+
+	response, err := s.run(keyCommandRequest{
+		Operation: "sign",
+		Algorithm: "RS256",
+		Message:   base64.StdEncoding.EncodeToString(msg),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("key command %s returned an invalid signature: %w", s.command[0], err)
+	}
+
+	digest := sha256.Sum256(msg)
+	if err := rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("key command %s returned a signature not matching its public key", s.command[0])
+	}
+
+	return signature, nil
+}
+
+// run sends a request to the helper and reads its response. The stderr of
+// the helper is passed through so it can prompt or report errors.
+func (s *commandSigner) run(request keyCommandRequest) (*keyCommandResponse, error) {
+	request.Version = 1
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key command request: %w", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("key command %s failed: %w", s.command[0], err)
+	}
+
+	var response keyCommandResponse
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return nil, fmt.Errorf("key command %s returned an invalid response: %w", s.command[0], err)
+	}
+
+	if response.Error != "" {
+		return nil, fmt.Errorf("key command %s failed: %s", s.command[0], response.Error)
+	}
+
+	return &response, nil
+}
+
+// parseRSAPublicKey parses a PEM encoded PKIX or PKCS #1 RSA public key
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("app keys must be RSA keys, got a %T public key", key)
+	}
+
+	return rsaKey, nil
+}
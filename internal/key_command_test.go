@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyCommandHelper acts as a --key-command helper when run by the tests
+// below, signing with the test key or misbehaving as told by
+// GH_TOKEN_TEST_KEY_COMMAND
+func TestKeyCommandHelper(t *testing.T) {
+	mode := os.Getenv("GH_TOKEN_TEST_KEY_COMMAND")
+	if mode == "" {
+		t.Skip("only run as a key command helper")
+	}
+
+	var request keyCommandRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		os.Exit(2)
+	}
+
+	signer, _ := readKey("fixtures/test-private-key.test.pem", nil)
+	key := signer.(*rsa.PrivateKey)
+	if mode == "wrong_key" {
+		key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	var response keyCommandResponse
+	switch {
+	case mode == "exit":
+		os.Exit(1)
+	case mode == "error":
+		response.Error = "access denied"
+	case request.Operation == "public_key":
+		der, _ := x509.MarshalPKIXPublicKey(signer.Public())
+		response.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	case request.Operation == "sign":
+		message, _ := base64.StdEncoding.DecodeString(request.Message)
+		digest := sha256.Sum256(message)
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		response.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+
+	_ = json.NewEncoder(os.Stdout).Encode(response)
+	os.Exit(0)
+}
+
+func TestCommandSigner(t *testing.T) {
+	key, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+
+	command := os.Args[0] + " -test.run=^TestKeyCommandHelper$"
+
+	tests := []struct {
+		name          string
+		mode          string
+		expectedError string
+	}{
+		{
+			name: "signs",
+			mode: "ok",
+		},
+		{
+			name:          "signature_from_another_key",
+			mode:          "wrong_key",
+			expectedError: "returned a signature not matching its public key",
+		},
+		{
+			name:          "reported_error",
+			mode:          "error",
+			expectedError: "failed: access denied",
+		},
+		{
+			name:          "exit_status",
+			mode:          "exit",
+			expectedError: "failed: exit status 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GH_TOKEN_TEST_KEY_COMMAND", tt.mode)
+
+			var signed string
+			signer, err := newCommandSigner(command)
+			if err == nil {
+				signed, err = generateJWT("123456", 10, signer)
+			}
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			_, err = jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+				return key.Public(), nil
+			}, jwt.WithValidMethods([]string{"RS256"}))
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// keyFlags returns the CLI flags selecting the private key, or the signer,
+// the app JWTs are signed with
+func keyFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "key",
+			Usage:    "Path to private key, - to read it from stdin, or an env://NAME, file://PATH or fd://N source. Repeat it to fall back to the next keys while GitHub rejects the previous ones during a key rotation",
+			Required: false,
+			Aliases:  []string{"k"},
+		},
+		&cli.StringFlag{
+			Name:     "key-env",
+			Usage:    "Name of an environment variable holding the private key, same as --key env://NAME",
+			Required: false,
+			Aliases:  []string{"key_env"},
+		},
+		&cli.StringFlag{
+			Name:     "key-fd",
+			Usage:    "File descriptor to read the private key from, same as --key fd://N",
+			Required: false,
+			Aliases:  []string{"key_fd"},
+		},
+		&cli.StringFlag{
+			Name:     "key-dir",
+			Usage:    "Directory of .pem private keys tried after --key, newest first by their file name as downloaded from GitHub",
+			Required: false,
+			Aliases:  []string{"key_dir"},
+		},
+		&cli.StringFlag{
+			Name:     "base64-key",
+			Usage:    "A base64 encoded private key, or a source of it as accepted by --key",
+			Required: false,
+			Aliases:  []string{"b", "base64_key"},
+		},
+		&cli.StringFlag{
+			Name:     "key-agent",
+			Usage:    "SHA256 fingerprint or comment of an RSA key loaded in the ssh-agent of SSH_AUTH_SOCK to sign with instead of a private key file",
+			Required: false,
+			Aliases:  []string{"key_agent"},
+		},
+		&cli.StringFlag{
+			Name:     "key-command",
+			Usage:    "Command, with its arguments separated by spaces, of a helper signing app JWTs over a JSON protocol on its stdin and stdout, e.g. with a cloud KMS",
+			Required: false,
+			Aliases:  []string{"key_command"},
+		},
+		&cli.StringFlag{
+			Name:     "pkcs11-key",
+			Usage:    "Label of the RSA private key to sign with in the PKCS#11 token given by --pkcs11-module and --pkcs11-token",
+			Required: false,
+			Aliases:  []string{"pkcs11_key"},
+		},
+		&cli.StringFlag{
+			Name:     "pkcs11-module",
+			Usage:    "Path to the PKCS#11 module of the token holding --pkcs11-key, example: /usr/lib/softhsm/libsofthsm2.so",
+			Required: false,
+			Aliases:  []string{"pkcs11_module"},
+		},
+		&cli.StringFlag{
+			Name:     "pkcs11-token",
+			Usage:    "Label of the PKCS#11 token holding --pkcs11-key",
+			Required: false,
+			Aliases:  []string{"pkcs11_token"},
+		},
+	}, keyUnlockFlags()...)
+}
+
+// keyUnlockFlags returns the CLI flags unlocking encrypted private keys and
+// PKCS#11 tokens
+func keyUnlockFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "key-passphrase",
+			Usage:    "Passphrase of an encrypted private key, prefer setting it through the environment. Prompted for on a terminal otherwise",
+			Required: false,
+			Aliases:  []string{"key_passphrase"},
+			EnvVars:  []string{"GH_TOKEN_KEY_PASSPHRASE"},
+		},
+		&cli.StringFlag{
+			Name:     "key-passphrase-file",
+			Usage:    "Path to a file holding the passphrase of an encrypted private key",
+			Required: false,
+			Aliases:  []string{"key_passphrase_file"},
+		},
+		&cli.StringFlag{
+			Name:     "pkcs11-pin",
+			Usage:    "User PIN of the PKCS#11 token, prefer setting it through the environment",
+			Required: false,
+			Aliases:  []string{"pkcs11_pin"},
+			EnvVars:  []string{"GH_TOKEN_PKCS11_PIN"},
+		},
+	}
+}
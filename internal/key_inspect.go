@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Link-/gh-token/ghtoken"
+	"github.com/google/go-github/v55/github"
+	"github.com/urfave/cli/v2"
+)
+
+// keyInspection describes an app key and, when it was verified, the app
+// it belongs to
+type keyInspection struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Size        int         `json:"size"`
+	Fingerprint string      `json:"fingerprint"`
+	App         *github.App `json:"app,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// KeyInspect is the entrypoint for the key inspect command
+func KeyInspect(c *cli.Context) error {
+	appID := c.String("app-id")
+	hostname, err := apiBaseURL(c.String("hostname"))
+	if err != nil {
+		return err
+	}
+
+	keys, err := loadAppKeys(c)
+	if err != nil {
+		return err
+	}
+	defer keys.close()
+
+	inspections, err := inspectKeys(c.Context, keys, hostname, appID)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(inspections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling key inspection to JSON: %w", err)
+	}
+
+	fmt.Println(string(bytes))
+
+	rejected := 0
+	for _, inspection := range inspections {
+		if inspection.Error != "" {
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d keys could not be verified for app %s", rejected, len(inspections), appID)
+	}
+
+	return nil
+}
+
+// inspectKeys describes the keys, and verifies that they belong to the app
+// when appID is not empty
+func inspectKeys(ctx context.Context, keys appKeys, hostname, appID string) ([]keyInspection, error) {
+	inspections := make([]keyInspection, 0, len(keys))
+	for _, key := range keys {
+		signer, err := key.get()
+		if err != nil {
+			return nil, err
+		}
+
+		inspection, err := describeKey(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+		inspection.Key = key.name
+
+		if appID != "" {
+			inspection.App, err = verifyAppKey(ctx, hostname, appID, signer)
+			if err != nil {
+				inspection.Error = err.Error()
+			}
+		}
+
+		inspections = append(inspections, *inspection)
+	}
+
+	return inspections, nil
+}
+
+// describeKey returns the type, size and fingerprint of a public key, which
+// is an RSA key since other keys are rejected when they are loaded. The
+// fingerprint is the one GitHub shows in the settings of the app, the
+// SHA-256 digest of the DER encoded public key.
+func describeKey(publicKey crypto.PublicKey) (*keyInspection, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode public key: %w", err)
+	}
+	digest := sha256.Sum256(der)
+
+	inspection := &keyInspection{Fingerprint: "SHA256:" + base64.StdEncoding.EncodeToString(digest[:])}
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		inspection.Type = "RSA"
+		inspection.Size = publicKey.N.BitLen()
+	default:
+		inspection.Type = fmt.Sprintf("%T", publicKey)
+	}
+
+	return inspection, nil
+}
+
+// verifyAppKey asks GitHub for the app authenticated by a JWT signed with
+// the key
+func verifyAppKey(ctx context.Context, hostname, appID string, signer crypto.Signer) (*github.App, error) {
+	jsonWebToken, err := generateJWT(appID, 1, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed generating JWT: %w", err)
+	}
+
+	app, err := apiClient(hostname).App(ctx, jsonWebToken)
+	var statusErr *ghtoken.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == 401 {
+		return nil, fmt.Errorf("GitHub rejected the JWT: the key was deleted from app %s, or belongs to another app", appID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed verifying the key: %w", err)
+	}
+
+	return app, nil
+}
@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/urfave/cli/v2"
+)
+
+// KeyInspectFlags returns the CLI flags for the key inspect command, the key
+// and hostname flags of the generate command
+func KeyInspectFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "app-id",
+			Usage:    "GitHub App ID. If specified, the keys are verified to belong to the app",
+			Required: false,
+			Aliases:  []string{"i", "app_id"},
+		},
+	}, withEnvVars(slices.Concat([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "Name of the configuration file profile providing the defaults of the other flags",
+			Required: false,
+			EnvVars:  []string{"GH_TOKEN_PROFILE"},
+		},
+		&cli.StringFlag{
+			Name:     "hostname",
+			Usage:    "GitHub Enterprise Server or GHE.com host, or API URL, example: github.example.com, octocorp.ghe.com or http://localhost:8080/api/v3",
+			Required: false,
+			Aliases:  []string{"o"},
+			Value:    "api.github.com",
+		},
+	}, keyFlags(), httpFlags()))...)
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectKeys(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tests := []struct {
+		name          string
+		appID         string
+		hostname      string
+		status        int
+		expectedApp   string
+		expectedError string
+	}{
+		{
+			name: "without_verification",
+		},
+		{
+			name:        "key_of_app",
+			appID:       "123456",
+			hostname:    "api.github.com",
+			status:      200,
+			expectedApp: "test-app",
+		},
+		{
+			name:          "key_of_another_app",
+			appID:         "654321",
+			hostname:      "github.example.com/api/v3",
+			status:        401,
+			expectedError: "GitHub rejected the JWT: the key was deleted from app 654321, or belongs to another app",
+		},
+		{
+			name:          "server_error",
+			appID:         "123456",
+			hostname:      "api.github.com",
+			status:        500,
+			expectedError: "failed verifying the key: unexpected status code: 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			httpmock.RegisterResponder("GET", "https://"+tt.hostname+"/app",
+				func(req *http.Request) (*http.Response, error) {
+					if tt.status != 200 {
+						return httpmock.NewStringResponse(tt.status, `{"message": "error"}`), nil
+					}
+					return httpmock.NewJsonResponse(200, &github.App{ID: github.Int64(123456), Slug: github.String("test-app")})
+				})
+
+			keys, err := loadAppKeys(createTestContextForExec(nil, nil))
+			assert.NoError(t, err)
+
+			inspections, err := inspectKeys(context.Background(), keys, tt.hostname, tt.appID)
+
+			assert.NoError(t, err)
+			assert.Len(t, inspections, 1)
+			assert.Equal(t, "fixtures/test-private-key.test.pem", inspections[0].Key)
+			assert.Equal(t, "RSA", inspections[0].Type)
+			assert.Equal(t, 2048, inspections[0].Size)
+			// openssl rsa -in KEY -pubout -outform DER | openssl sha256 -binary | openssl base64
+			assert.Equal(t, "SHA256:sQTuu7xVq2xQDbWzL7bXgItDHCnkzL0Q5SwdplKHgRg=", inspections[0].Fingerprint)
+			assert.Equal(t, tt.expectedError, inspections[0].Error)
+			assert.Equal(t, tt.expectedApp, inspections[0].App.GetSlug())
+			if tt.appID == "" {
+				assert.Equal(t, 0, httpmock.GetTotalCallCount())
+			}
+		})
+	}
+}
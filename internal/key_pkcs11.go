@@ -0,0 +1,204 @@
+//go:build pkcs11
+
+package internal
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// sha256DigestInfo is the DER prefix of a PKCS#1 v1.5 SHA-256 DigestInfo,
+// which CKM_RSA_PKCS expects in front of the digest
+var sha256DigestInfo = []byte{0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20}
+
+// pkcs11Signer signs app JWTs with an RSA private key kept in a PKCS#11
+// token, e.g. an HSM. Close logs out of the token and unloads the module.
+type pkcs11Signer struct {
+	mu          sync.Mutex
+	ctx         *pkcs11.Ctx
+	initialized bool
+	session     pkcs11.SessionHandle
+	opened      bool
+	loggedIn    bool
+	key         pkcs11.ObjectHandle
+	publicKey   *rsa.PublicKey
+}
+
+// newPKCS11Signer loads the module, logs into the token and finds the RSA
+// private key with the configured labels
+func newPKCS11Signer(config pkcs11Config) (crypto.Signer, error) {
+	if config.module == "" || config.token == "" {
+		return nil, fmt.Errorf("--pkcs11-key requires --pkcs11-module and --pkcs11-token")
+	}
+
+	ctx := pkcs11.New(config.module)
+	if ctx == nil {
+		return nil, fmt.Errorf("unable to load PKCS#11 module %s", config.module)
+	}
+	s := &pkcs11Signer{ctx: ctx}
+
+	err := ctx.Initialize()
+	if err != nil && !isPKCS11Error(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		_ = s.Close()
+		return nil, fmt.Errorf("unable to initialize PKCS#11 module %s: %w", config.module, err)
+	}
+	// Leave the module initialized when another user of it did so
+	s.initialized = err == nil
+
+	slot, err := findPKCS11Slot(ctx, config.token)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	s.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("unable to open a session on PKCS#11 token %s: %w", config.token, err)
+	}
+	s.opened = true
+
+	if config.pin != "" {
+		err = ctx.Login(s.session, pkcs11.CKU_USER, config.pin)
+		if err != nil && !isPKCS11Error(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+			_ = s.Close()
+			return nil, fmt.Errorf("unable to log into PKCS#11 token %s: %w", config.token, err)
+		}
+		s.loggedIn = err == nil
+	}
+
+	s.key, err = findPKCS11Key(ctx, s.session, config.key)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	attributes, err := ctx.GetAttributeValue(s.session, s.key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil || len(attributes) != 2 {
+		_ = s.Close()
+		return nil, fmt.Errorf("unable to read the public key of PKCS#11 key %s: %w", config.key, err)
+	}
+
+	s.publicKey = &rsa.PublicKey{
+		N: new(big.Int).SetBytes(attributes[0].Value),
+		E: int(new(big.Int).SetBytes(attributes[1].Value).Int64()),
+	}
+
+	return s, nil
+}
+
+// Close logs out of the token, closes the session and unloads the module
+func (s *pkcs11Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return nil
+	}
+
+	var errs []error
+	if s.loggedIn {
+		errs = append(errs, s.ctx.Logout(s.session))
+	}
+	if s.opened {
+		errs = append(errs, s.ctx.CloseSession(s.session))
+	}
+	if s.initialized {
+		errs = append(errs, s.ctx.Finalize())
+	}
+	s.ctx.Destroy()
+	s.ctx = nil
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("unable to release PKCS#11 token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("PKCS#11 keys only sign with SHA-256")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return nil, fmt.Errorf("unable to sign with PKCS#11 key: the token was closed")
+	}
+
+	err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign with PKCS#11 key: %w", err)
+	}
+
+	signature, err := s.ctx.Sign(s.session, append(append([]byte{}, sha256DigestInfo...), digest...))
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign with PKCS#11 key: %w", err)
+	}
+
+	return signature, nil
+}
+
+func findPKCS11Slot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list PKCS#11 slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == label {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no PKCS#11 token is labelled %s", label)
+}
+
+func findPKCS11Key(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, label string) (pkcs11.ObjectHandle, error) {
+	err := ctx.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to search PKCS#11 keys: %w", err)
+	}
+
+	keys, _, err := ctx.FindObjects(session, 2)
+	_ = ctx.FindObjectsFinal(session)
+	if err != nil {
+		return 0, fmt.Errorf("unable to search PKCS#11 keys: %w", err)
+	}
+
+	switch len(keys) {
+	case 0:
+		return 0, fmt.Errorf("no RSA private key is labelled %s in the PKCS#11 token", label)
+	case 1:
+		return keys[0], nil
+	default:
+		return 0, fmt.Errorf("several RSA private keys are labelled %s in the PKCS#11 token", label)
+	}
+}
+
+func isPKCS11Error(err error, code uint) bool {
+	var pkcs11Err pkcs11.Error
+	return errors.As(err, &pkcs11Err) && uint(pkcs11Err) == code
+}
@@ -0,0 +1,12 @@
+//go:build !pkcs11
+
+package internal
+
+import (
+	"crypto"
+	"fmt"
+)
+
+func newPKCS11Signer(config pkcs11Config) (crypto.Signer, error) {
+	return nil, fmt.Errorf("this build of gh-token does not support PKCS#11, rebuild it with -tags pkcs11")
+}
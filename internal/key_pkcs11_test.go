@@ -0,0 +1,76 @@
+//go:build pkcs11
+
+package internal
+
+import (
+	"crypto/rsa"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPKCS11Signer runs against a SoftHSM token holding the test key:
+//
+//	softhsm2-util --init-token --free --label gh-token --pin 1234 --so-pin 1234
+//	softhsm2-util --import fixtures/test-private-key.test.pem --token gh-token \
+//	    --label gh-token-test --id 01 --pin 1234
+//	GH_TOKEN_TEST_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so go test -tags pkcs11 ./internal
+func TestPKCS11Signer(t *testing.T) {
+	module := os.Getenv("GH_TOKEN_TEST_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("GH_TOKEN_TEST_PKCS11_MODULE is not set")
+	}
+
+	key, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		config        pkcs11Config
+		expectedError string
+	}{
+		{
+			name:   "key",
+			config: pkcs11Config{module: module, token: "gh-token", key: "gh-token-test", pin: "1234"},
+		},
+		{
+			name:          "unknown_token",
+			config:        pkcs11Config{module: module, token: "nope", key: "gh-token-test", pin: "1234"},
+			expectedError: "no PKCS#11 token is labelled nope",
+		},
+		{
+			name:          "unknown_key",
+			config:        pkcs11Config{module: module, token: "gh-token", key: "nope", pin: "1234"},
+			expectedError: "no RSA private key is labelled nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := newPKCS11Signer(tt.config)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, key.(*rsa.PrivateKey).PublicKey.Equal(signer.Public()))
+
+			signed, err := generateJWT("123456", 10, signer)
+			assert.NoError(t, err)
+			_, err = jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+				return key.Public(), nil
+			}, jwt.WithValidMethods([]string{"RS256"}))
+			assert.NoError(t, err)
+
+			assert.NoError(t, signer.(io.Closer).Close())
+			_, err = generateJWT("123456", 10, signer)
+			assert.ErrorContains(t, err, "the token was closed")
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKeySource(t *testing.T) {
+	keyBytes, err := os.ReadFile("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+	absPath, err := filepath.Abs("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+
+	t.Setenv("GH_TOKEN_TEST_KEY", string(keyBytes))
+
+	tests := []struct {
+		name          string
+		source        func(t *testing.T) string
+		expectedError string
+	}{
+		{
+			name:   "path",
+			source: func(t *testing.T) string { return "fixtures/test-private-key.test.pem" },
+		},
+		{
+			name:   "file_uri",
+			source: func(t *testing.T) string { return "file://" + absPath },
+		},
+		{
+			name:   "env_uri",
+			source: func(t *testing.T) string { return "env://GH_TOKEN_TEST_KEY" },
+		},
+		{
+			name: "fd_uri",
+			source: func(t *testing.T) string {
+				return "fd://" + strconv.Itoa(int(pipeWith(t, keyBytes).Fd()))
+			},
+		},
+		{
+			name: "stdin",
+			source: func(t *testing.T) string {
+				stdin := os.Stdin
+				os.Stdin = pipeWith(t, keyBytes)
+				t.Cleanup(func() {
+					_ = os.Stdin.Close()
+					os.Stdin = stdin
+				})
+				return "-"
+			},
+		},
+		{
+			name:          "unset_env",
+			source:        func(t *testing.T) string { return "env://GH_TOKEN_TEST_UNSET" },
+			expectedError: "GH_TOKEN_TEST_UNSET is not set",
+		},
+		{
+			name:          "invalid_fd",
+			source:        func(t *testing.T) string { return "fd://stdin" },
+			expectedError: "invalid key file descriptor \"stdin\"",
+		},
+		{
+			name:          "unsupported_scheme",
+			source:        func(t *testing.T) string { return "https://example.com/key.pem" },
+			expectedError: "unsupported key source https://",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readKeySource(tt.source(t))
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, keyBytes, data)
+			}
+		})
+	}
+}
+
+func TestAppSignerKeySources(t *testing.T) {
+	keyBytes, err := os.ReadFile("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+
+	t.Setenv("GH_TOKEN_TEST_KEY", string(keyBytes))
+	t.Setenv("GH_TOKEN_TEST_KEY_BASE64", base64.StdEncoding.EncodeToString(keyBytes))
+
+	tests := []struct {
+		name          string
+		flags         map[string]interface{}
+		expectedError string
+	}{
+		{
+			name: "key_env",
+			flags: map[string]interface{}{
+				"key":     []string{},
+				"key-env": "GH_TOKEN_TEST_KEY",
+			},
+		},
+		{
+			name: "base64_key_env_uri",
+			flags: map[string]interface{}{
+				"key":        []string{},
+				"base64-key": "env://GH_TOKEN_TEST_KEY_BASE64",
+			},
+		},
+		{
+			name: "key_and_base64_key",
+			flags: map[string]interface{}{
+				"base64-key": "env://GH_TOKEN_TEST_KEY_BASE64",
+			},
+			expectedError: "only one of --key, --base64-key, --key-agent, --pkcs11-key or --key-command may be specified",
+		},
+		{
+			name: "key_dir_without_keys",
+			flags: map[string]interface{}{
+				"key":     []string{},
+				"key-dir": "fixtures/..",
+			},
+			expectedError: "no .pem key found in fixtures/..",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := loadAppKeys(createTestContextForExec(tt.flags, nil))
+			var signer crypto.Signer
+			if err == nil {
+				signer, err = keys[0].get()
+			}
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, signer)
+			}
+		})
+	}
+}
+
+func TestAppKeysFallback(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	key, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+	keyBytes, err := os.ReadFile("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+
+	staleKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	staleDER, err := x509.MarshalPKCS8PrivateKey(staleKey)
+	assert.NoError(t, err)
+	staleBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: staleDER})
+
+	// Only JWTs signed by the fixture key are accepted
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		func(req *http.Request) (*http.Response, error) {
+			_, err := jwt.Parse(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), func(token *jwt.Token) (interface{}, error) {
+				return key.Public(), nil
+			})
+			if err != nil {
+				return httpmock.NewStringResponse(401, `{"message": "A JSON web token could not be decoded"}`), nil
+			}
+			return httpmock.NewJsonResponse(201, &github.InstallationToken{Token: github.String("ghs_test_token_123")})
+		})
+
+	tests := []struct {
+		name          string
+		files         map[string][]byte
+		expectedCalls int
+		expectedError string
+	}{
+		{
+			name: "primary_key_accepted",
+			files: map[string][]byte{
+				"app.2024-06-01.private-key.pem": keyBytes,
+				"app.2023-01-01.private-key.pem": staleBytes,
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "falls_back_to_next_key",
+			files: map[string][]byte{
+				"app.2024-06-01.private-key.pem": staleBytes,
+				"app.2023-01-01.private-key.pem": keyBytes,
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "all_keys_rejected",
+			files: map[string][]byte{
+				"app.2024-06-01.private-key.pem": staleBytes,
+				"app.2023-01-01.private-key.pem": staleBytes,
+			},
+			expectedCalls: 2,
+			expectedError: "unexpected status code: 401",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.ZeroCallCounters()
+
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0600))
+			}
+
+			token, _, err := issueToken(context.Background(), createTestContextForExec(map[string]interface{}{
+				"key":     []string{},
+				"key-dir": dir,
+			}, nil), nil)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "ghs_test_token_123", token.GetToken())
+			}
+			assert.Equal(t, tt.expectedCalls, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+// pipeWith returns the read end of a pipe holding data, which the reader of
+// the key closes
+func pipeWith(t *testing.T, data []byte) *os.File {
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	_, err = writer.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	return reader
+}
+
+func TestParseKeyRequiresPassphrase(t *testing.T) {
+	signer, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+
+	//nolint:staticcheck // legacy encryption is what openssl rsa -aes256 writes
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(signer.(*rsa.PrivateKey)), []byte("correct horse"), x509.PEMCipherAES256)
+	assert.NoError(t, err)
+	encrypted := pem.EncodeToMemory(block)
+
+	_, err = parseKey(encrypted, nil)
+	assert.ErrorContains(t, err, "provide its passphrase with --key-passphrase")
+
+	_, err = readKeyBase64(base64.StdEncoding.EncodeToString(encrypted), nil)
+	assert.ErrorContains(t, err, "provide its passphrase with --key-passphrase")
+
+	parsed, err := parseKey(encrypted, func() ([]byte, error) { return []byte("correct horse"), nil })
+	assert.NoError(t, err)
+	assert.True(t, signer.(*rsa.PrivateKey).Equal(parsed))
+}
+
+// closingSigner records whether it was closed
+type closingSigner struct {
+	crypto.Signer
+	closed bool
+}
+
+func (s *closingSigner) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestAppKeysClose(t *testing.T) {
+	signer, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+
+	loaded := &closingSigner{Signer: signer}
+	loads := 0
+	keys := appKeys{
+		{name: "loaded", load: func() (crypto.Signer, error) { return loaded, nil }},
+		{name: "unused", load: func() (crypto.Signer, error) {
+			loads++
+			return &closingSigner{Signer: signer}, nil
+		}},
+	}
+
+	_, err = keys[0].get()
+	assert.NoError(t, err)
+
+	keys.close()
+	assert.True(t, loaded.closed)
+
+	// A key which was never loaded is not loaded to close it, nor afterwards
+	_, err = keys[1].get()
+	assert.EqualError(t, err, "key unused is closed")
+	assert.Equal(t, 0, loads)
+}
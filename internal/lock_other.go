@@ -0,0 +1,21 @@
+//go:build (!unix && !windows) || aix
+
+package internal
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile only creates path on platforms without file locking support, so
+// concurrent processes are not serialized there
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file %s: %w", path, err)
+	}
+
+	return func() {
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package internal
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported reports whether readPeerCredentials is available
+const peerCredentialsSupported = true
+
+// readPeerCredentials returns the credentials of the process connected to a
+// Unix socket, as recorded by the kernel when it connected
+func readPeerCredentials(conn net.Conn) (*peerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("peer credentials are only available on Unix sockets")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("unable to access socket: %w", err)
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read peer credentials: %w", err)
+	}
+
+	return &peerCredentials{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+}
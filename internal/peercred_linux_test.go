@@ -0,0 +1,53 @@
+//go:build linux
+
+package internal
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPeerCredentials(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "broker.sock"))
+	assert.NoError(t, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	client, err := net.Dial("unix", listener.Addr().String())
+	assert.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	peer, err := readPeerCredentials(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(os.Getuid()), peer.UID)
+	assert.Equal(t, uint32(os.Getgid()), peer.GID)
+	assert.Equal(t, int32(os.Getpid()), peer.PID)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() {
+		_ = tcpListener.Close()
+	}()
+
+	tcpClient, err := net.Dial("tcp", tcpListener.Addr().String())
+	assert.NoError(t, err)
+	defer func() {
+		_ = tcpClient.Close()
+	}()
+
+	_, err = readPeerCredentials(tcpClient)
+	assert.EqualError(t, err, "peer credentials are only available on Unix sockets")
+}
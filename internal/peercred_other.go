@@ -0,0 +1,15 @@
+//go:build !linux
+
+package internal
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerCredentialsSupported reports whether readPeerCredentials is available
+const peerCredentialsSupported = false
+
+func readPeerCredentials(_ net.Conn) (*peerCredentials, error) {
+	return nil, fmt.Errorf("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v55/github"
+)
+
+// permissionLevels are the access levels accepted by the GitHub API when
+// scoping an installation token
+var permissionLevels = []string{"read", "write", "admin"}
+
+// parsePermissions converts a list of name:level pairs, e.g.
+// contents:read or issues:write, into an InstallationPermissions struct.
+// Permission names are validated against the JSON field names of
+// github.InstallationPermissions.
+func parsePermissions(specs []string) (*github.InstallationPermissions, error) {
+	permissions := &github.InstallationPermissions{}
+	fields := permissionFields()
+	count := 0
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		name, level, found := strings.Cut(spec, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		level = strings.ToLower(strings.TrimSpace(level))
+		if !found || name == "" || level == "" {
+			return nil, fmt.Errorf("invalid permission %q, expected the format name:level", spec)
+		}
+
+		index, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown permission %q, valid permissions are: %s", name, strings.Join(permissionNames(), ", "))
+		}
+
+		if !slices.Contains(permissionLevels, level) {
+			return nil, fmt.Errorf("invalid access level %q for permission %q, valid levels are: %s", level, name, strings.Join(permissionLevels, ", "))
+		}
+
+		reflect.ValueOf(permissions).Elem().Field(index).Set(reflect.ValueOf(github.String(level)))
+		count++
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	return permissions, nil
+}
+
+// parseRepositoryIDs converts a list of repository IDs to int64 values
+func parseRepositoryIDs(values []string) ([]int64, error) {
+	var ids []int64
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid repository ID %q", value)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// installationTokenOptions builds the request body used to scope an
+// installation token to a set of repositories and permissions. It returns nil
+// when no scope was requested so the token inherits the installation's full
+// permission set.
+func installationTokenOptions(repositories, repositoryIDs, permissions []string) (*github.InstallationTokenOptions, error) {
+	ids, err := parseRepositoryIDs(repositoryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	perms, err := parsePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, repository := range repositories {
+		repository = strings.TrimSpace(repository)
+		if repository == "" {
+			continue
+		}
+		if strings.Contains(repository, "/") {
+			return nil, fmt.Errorf("invalid repository name %q, repositories must be given without the owner", repository)
+		}
+		names = append(names, repository)
+	}
+
+	if len(names) == 0 && len(ids) == 0 && perms == nil {
+		return nil, nil
+	}
+
+	return &github.InstallationTokenOptions{
+		Repositories:  names,
+		RepositoryIDs: ids,
+		Permissions:   perms,
+	}, nil
+}
+
+// permissionFields maps the JSON name of each InstallationPermissions field
+// to its index in the struct
+func permissionFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(github.InstallationPermissions{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+
+	return fields
+}
+
+// permissionNames returns the sorted list of valid permission names
+func permissionNames() []string {
+	var names []string
+	for name := range permissionFields() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		name          string
+		specs         []string
+		expected      *github.InstallationPermissions
+		expectedError string
+	}{
+		{
+			name:  "single_permission",
+			specs: []string{"contents:read"},
+			expected: &github.InstallationPermissions{
+				Contents: github.String("read"),
+			},
+		},
+		{
+			name:  "multiple_permissions_with_whitespace_and_case",
+			specs: []string{" Contents : READ ", "issues:write", "pull_requests:write"},
+			expected: &github.InstallationPermissions{
+				Contents:     github.String("read"),
+				Issues:       github.String("write"),
+				PullRequests: github.String("write"),
+			},
+		},
+		{
+			name:  "admin_level",
+			specs: []string{"organization_administration:admin"},
+			expected: &github.InstallationPermissions{
+				OrganizationAdministration: github.String("admin"),
+			},
+		},
+		{
+			name:     "empty_specs",
+			specs:    []string{"", " "},
+			expected: nil,
+		},
+		{
+			name:          "missing_level",
+			specs:         []string{"contents"},
+			expectedError: "invalid permission \"contents\", expected the format name:level",
+		},
+		{
+			name:          "unknown_permission",
+			specs:         []string{"contents:read", "repo:write"},
+			expectedError: "unknown permission \"repo\"",
+		},
+		{
+			name:          "invalid_level",
+			specs:         []string{"contents:owner"},
+			expectedError: "invalid access level \"owner\" for permission \"contents\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parsePermissions(tt.specs)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestInstallationTokenOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		repositories  []string
+		repositoryIDs []string
+		permissions   []string
+		expected      *github.InstallationTokenOptions
+		expectedError string
+	}{
+		{
+			name:     "no_scope_returns_nil",
+			expected: nil,
+		},
+		{
+			name:          "repositories_and_ids",
+			repositories:  []string{"gh-token", "cli"},
+			repositoryIDs: []string{"1296269", " 42 "},
+			expected: &github.InstallationTokenOptions{
+				Repositories:  []string{"gh-token", "cli"},
+				RepositoryIDs: []int64{1296269, 42},
+			},
+		},
+		{
+			name:        "permissions_only",
+			permissions: []string{"metadata:read"},
+			expected: &github.InstallationTokenOptions{
+				Permissions: &github.InstallationPermissions{
+					Metadata: github.String("read"),
+				},
+			},
+		},
+		{
+			name:          "invalid_repository_id",
+			repositoryIDs: []string{"abc"},
+			expectedError: "invalid repository ID \"abc\"",
+		},
+		{
+			name:          "repository_with_owner",
+			repositories:  []string{"Link-/gh-token"},
+			expectedError: "repositories must be given without the owner",
+		},
+		{
+			name:          "invalid_permission",
+			permissions:   []string{"contents=read"},
+			expectedError: "expected the format name:level",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := installationTokenOptions(tt.repositories, tt.repositoryIDs, tt.permissions)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
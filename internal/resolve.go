@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Link-/gh-token/ghtoken"
+	"github.com/google/go-github/v55/github"
+)
+
+// errInstallationNotFound is returned when the app is not installed on the
+// requested account or repository
+var errInstallationNotFound = ghtoken.ErrInstallationNotFound
+
+// retrieveOwnerInstallationID resolves the installation of the app on an
+// organization, falling back to a user account when no organization with
+// that name has the app installed
+func retrieveOwnerInstallationID(ctx context.Context, hostname, jwt, owner string) (string, error) {
+	owner = strings.TrimSpace(owner)
+	if owner == "" || strings.Contains(owner, "/") {
+		return "", fmt.Errorf("invalid owner %q, expected an organization or user login", owner)
+	}
+
+	client := apiClient(hostname)
+	installation, err := client.OrganizationInstallation(ctx, jwt, owner)
+	if errors.Is(err, errInstallationNotFound) {
+		installation, err = client.UserInstallation(ctx, jwt, owner)
+	}
+	if errors.Is(err, errInstallationNotFound) {
+		return "", fmt.Errorf("the app is not installed on %s: %w", owner, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(installation.GetID(), 10), nil
+}
+
+// retrieveRepositoryInstallationID resolves the installation of the app on a
+// repository given in the owner/name format
+func retrieveRepositoryInstallationID(ctx context.Context, hostname, jwt, repository string) (string, error) {
+	owner, name, err := splitRepository(repository)
+	if err != nil {
+		return "", err
+	}
+
+	installation, err := apiClient(hostname).RepositoryInstallation(ctx, jwt, owner, name)
+	if errors.Is(err, errInstallationNotFound) {
+		return "", fmt.Errorf("the app is not installed on %s/%s: %w", owner, name, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(installation.GetID(), 10), nil
+}
+
+// splitRepository splits a repository in the owner/name format
+func splitRepository(repository string) (string, string, error) {
+	owner, name, found := strings.Cut(strings.TrimSpace(repository), "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid repository %q, expected the format owner/name", repository)
+	}
+
+	return owner, name, nil
+}
+
+// installationCriteria narrows down the installations of an app when no
+// installation was explicitly requested
+type installationCriteria struct {
+	// AccountType is either User or Organization
+	AccountType string
+	// AccountLogin is the login of the account the app is installed on
+	AccountLogin string
+	// First selects the first matching installation instead of failing when
+	// several installations match
+	First bool
+}
+
+// selectInstallation returns the only installation matching the criteria. It
+// fails when none match, or when several match and criteria.First is not set,
+// so that a token is never silently generated for the wrong account.
+func selectInstallation(installations []github.Installation, criteria installationCriteria) (*github.Installation, error) {
+	accountType := strings.TrimSpace(criteria.AccountType)
+	if accountType != "" && !strings.EqualFold(accountType, "User") && !strings.EqualFold(accountType, "Organization") {
+		return nil, fmt.Errorf("invalid account type %q, valid types are: User, Organization", accountType)
+	}
+	accountLogin := strings.TrimSpace(criteria.AccountLogin)
+
+	var candidates []github.Installation
+	for _, installation := range installations {
+		if accountType != "" && !strings.EqualFold(installation.GetAccount().GetType(), accountType) {
+			continue
+		}
+		if accountLogin != "" && !strings.EqualFold(installation.GetAccount().GetLogin(), accountLogin) {
+			continue
+		}
+		candidates = append(candidates, installation)
+	}
+
+	if len(candidates) == 0 {
+		if len(installations) == 0 {
+			return nil, fmt.Errorf("the app has no installations")
+		}
+		return nil, fmt.Errorf("none of the %d installations of the app match the given account type or login", len(installations))
+	}
+
+	if len(candidates) > 1 && !criteria.First {
+		var lines []string
+		for _, candidate := range candidates {
+			login := candidate.GetAccount().GetLogin()
+			if login == "" {
+				login = "unknown account"
+			}
+			lines = append(lines, fmt.Sprintf("  %s (ID %d)", login, candidate.GetID()))
+		}
+
+		return nil, fmt.Errorf("found %d matching installations, select one with --installation-id, --owner, --account-login or --first:\n%s", len(candidates), strings.Join(lines, "\n"))
+	}
+
+	return &candidates[0], nil
+}
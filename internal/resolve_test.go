@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrieveOwnerInstallationID(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tests := []struct {
+		name           string
+		hostname       string
+		owner          string
+		setupMocks     func()
+		expectedResult string
+		expectedCalls  map[string]int
+		expectedError  string
+	}{
+		{
+			name:     "organization_installation",
+			hostname: "api.github.com",
+			owner:    "octo-org",
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/orgs/octo-org/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+			},
+			expectedResult: "12345",
+			expectedCalls: map[string]int{
+				"GET https://api.github.com/orgs/octo-org/installation":  1,
+				"GET https://api.github.com/users/octo-org/installation": 0,
+			},
+		},
+		{
+			name:     "falls_back_to_user_installation",
+			hostname: "api.github.com",
+			owner:    "octocat",
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/orgs/octocat/installation",
+					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+				httpmock.RegisterResponder("GET", "https://api.github.com/users/octocat/installation",
+					httpmock.NewStringResponder(200, `{"id": 67890}`))
+			},
+			expectedResult: "67890",
+			expectedCalls: map[string]int{
+				"GET https://api.github.com/orgs/octocat/installation":  1,
+				"GET https://api.github.com/users/octocat/installation": 1,
+			},
+		},
+		{
+			name:     "custom_hostname",
+			hostname: "github.company.com/api/v3",
+			owner:    "octo-org",
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://github.company.com/api/v3/orgs/octo-org/installation",
+					httpmock.NewStringResponder(200, `{"id": 12345}`))
+			},
+			expectedResult: "12345",
+		},
+		{
+			name:     "not_installed",
+			hostname: "api.github.com",
+			owner:    "someone",
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/orgs/someone/installation",
+					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+				httpmock.RegisterResponder("GET", "https://api.github.com/users/someone/installation",
+					httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+			},
+			expectedError: "the app is not installed on someone: installation not found",
+		},
+		{
+			name:     "unexpected_status_code",
+			hostname: "api.github.com",
+			owner:    "octo-org",
+			setupMocks: func() {
+				httpmock.RegisterResponder("GET", "https://api.github.com/orgs/octo-org/installation",
+					httpmock.NewStringResponder(401, `{"message": "Bad credentials"}`))
+			},
+			expectedError: "unexpected status code: 401",
+		},
+		{
+			name:          "invalid_owner",
+			hostname:      "api.github.com",
+			owner:         "octo-org/repo",
+			setupMocks:    func() {},
+			expectedError: "invalid owner \"octo-org/repo\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			tt.setupMocks()
+
+			result, err := retrieveOwnerInstallationID(context.Background(), tt.hostname, "test.jwt.token", tt.owner)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Equal(t, "", result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResult, result)
+			}
+
+			info := httpmock.GetCallCountInfo()
+			for call, count := range tt.expectedCalls {
+				assert.Equal(t, count, info[call], call)
+			}
+		})
+	}
+}
+
+func TestRetrieveRepositoryInstallationID(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tests := []struct {
+		name           string
+		repository     string
+		responseCode   int
+		responseBody   string
+		expectedResult string
+		expectedError  string
+	}{
+		{
+			name:           "successful_retrieval",
+			repository:     "Link-/gh-token",
+			responseCode:   200,
+			responseBody:   `{"id": 12345}`,
+			expectedResult: "12345",
+		},
+		{
+			name:          "not_installed",
+			repository:    "Link-/gh-token",
+			responseCode:  404,
+			responseBody:  `{"message": "Not Found"}`,
+			expectedError: "the app is not installed on Link-/gh-token",
+		},
+		{
+			name:          "missing_installation_id",
+			repository:    "Link-/gh-token",
+			responseCode:  200,
+			responseBody:  `{}`,
+			expectedError: "response body does not contain an installation ID",
+		},
+		{
+			name:          "invalid_json_response",
+			repository:    "Link-/gh-token",
+			responseCode:  200,
+			responseBody:  "invalid json",
+			expectedError: "unable to unmarshal response body",
+		},
+		{
+			name:          "invalid_repository_format",
+			repository:    "gh-token",
+			expectedError: "invalid repository \"gh-token\", expected the format owner/name",
+		},
+		{
+			name:          "too_many_segments",
+			repository:    "Link-/gh-token/extra",
+			expectedError: "expected the format owner/name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+
+			httpmock.RegisterResponder("GET", "https://api.github.com/repos/Link-/gh-token/installation",
+				func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, "Bearer test.jwt.token", req.Header.Get("Authorization"))
+					return httpmock.NewStringResponse(tt.responseCode, tt.responseBody), nil
+				})
+
+			result, err := retrieveRepositoryInstallationID(context.Background(), "api.github.com", "test.jwt.token", tt.repository)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Equal(t, "", result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResult, result)
+			}
+		})
+	}
+}
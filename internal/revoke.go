@@ -1,9 +1,8 @@
 package internal
 
 import (
+	"context"
 	"fmt"
-	"net/http"
-	"strings"
 
 	"github.com/urfave/cli/v2"
 )
@@ -11,15 +10,28 @@ import (
 // Revoke is the entrypoint for the revoke command
 func Revoke(c *cli.Context) error {
 	token := c.String("token")
-	hostname := strings.ToLower(c.String("hostname"))
 	silent := c.Bool("silent")
 
-	if hostname != "api.github.com" && !strings.Contains(hostname, "/api/v3") {
-		endpoint := fmt.Sprintf("%s/api/v3", hostname)
-		hostname = strings.TrimSuffix(endpoint, "/")
+	hostname, err := apiBaseURL(c.String("hostname"))
+	if err != nil {
+		return err
+	}
+
+	// Evict the token first so the cache never serves it once revoked, even
+	// when the revocation fails because the token is already invalid
+	if c.Bool("cache") {
+		cache, err := openTokenCache(c)
+		if err != nil {
+			return err
+		}
+
+		err = cache.evict(token)
+		if err != nil {
+			return err
+		}
 	}
 
-	err := revokeToken(hostname, token)
+	err = revokeToken(c.Context, hostname, token)
 	if err != nil {
 		return fmt.Errorf("failed revoking installation token: %w", err)
 	}
@@ -30,29 +42,6 @@ func Revoke(c *cli.Context) error {
 	return nil
 }
 
-func revokeToken(hostname, token string) error {
-	endpoint := fmt.Sprintf("https://%s/installation/token", hostname)
-	req, err := http.NewRequest("DELETE", endpoint, nil)
-	if err != nil {
-		return fmt.Errorf("unable to create DELETE request to %s: %w", endpoint, err)
-	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Add("Accept", "application/vnd.github+json")
-	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-	req.Header.Add("User-Agent", "Link-/gh-token")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("unable to DELETE to %s: %w", endpoint, err)
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	if resp.StatusCode != 204 {
-		return fmt.Errorf("token might be invalid or not properly formatted. Unexpected status code: %d", resp.StatusCode)
-	}
-
-	return nil
+func revokeToken(ctx context.Context, hostname, token string) error {
+	return apiClient(hostname).RevokeInstallationToken(ctx, token)
 }
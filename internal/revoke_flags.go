@@ -1,19 +1,29 @@
 package internal
 
-import "github.com/urfave/cli/v2"
+import (
+	"slices"
+
+	"github.com/urfave/cli/v2"
+)
 
 // RevokeFlags returns the CLI flags for the revoke command
 func RevokeFlags() []cli.Flag {
-	return []cli.Flag{
+	return withEnvVars(slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:     "token",
 			Usage:    "GitHub App installation Token",
 			Required: true,
 			Aliases:  []string{"t"},
 		},
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "Name of the configuration file profile providing the defaults of the other flags",
+			Required: false,
+			EnvVars:  []string{"GH_TOKEN_PROFILE"},
+		},
 		&cli.StringFlag{
 			Name:     "hostname",
-			Usage:    "GitHub Enterprise Server API endpoint, example: github.example.com",
+			Usage:    "GitHub Enterprise Server or GHE.com host, or API URL, example: github.example.com, octocorp.ghe.com or http://localhost:8080/api/v3",
 			Required: false,
 			Aliases:  []string{"o"},
 			Value:    "api.github.com",
@@ -24,5 +34,5 @@ func RevokeFlags() []cli.Flag {
 			Aliases: []string{"s"},
 			Value:   false,
 		},
-	}
+	}, cacheFlags(), httpFlags()))
 }
@@ -1,11 +1,15 @@
 package internal
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/google/go-github/v55/github"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
@@ -177,9 +181,7 @@ func TestRevoke(t *testing.T) {
 				"silent":   true,
 			},
 			setupMocks: func() {
-				// The hostname processing logic preserves the trailing slash when /api/v3 is already present,
-				// resulting in a double slash in the final URL
-				httpmock.RegisterResponder("DELETE", "https://github.company.com/api/v3//installation/token",
+				httpmock.RegisterResponder("DELETE", "https://github.company.com/api/v3/installation/token",
 					httpmock.NewStringResponder(204, ""))
 			},
 			expectedError: "",
@@ -299,7 +301,7 @@ func TestRevokeToken(t *testing.T) {
 			httpmock.RegisterResponder("DELETE", endpoint,
 				httpmock.NewStringResponder(tt.responseCode, tt.responseBody))
 
-			err := revokeToken(tt.hostname, tt.token)
+			err := revokeToken(context.Background(), tt.hostname, tt.token)
 
 			if tt.expectedError != "" {
 				assert.Error(t, err)
@@ -354,7 +356,7 @@ func TestRevokeTokenNetworkErrors(t *testing.T) {
 			httpmock.Reset()
 			tt.setupMock()
 
-			err := revokeToken(tt.hostname, tt.token)
+			err := revokeToken(context.Background(), tt.hostname, tt.token)
 
 			assert.Error(t, err)
 			if tt.expectedError != "" {
@@ -457,3 +459,36 @@ func TestRevokeAdvancedCases(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokeEvictsCachedToken(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("DELETE", "https://api.github.com/installation/token",
+		httpmock.NewStringResponder(204, ""))
+
+	cacheDir := t.TempDir()
+	cache, err := newTokenCache(cacheDir, defaultCacheMargin, nil)
+	assert.NoError(t, err)
+
+	key := cacheKey{Hostname: "api.github.com", AppID: "123", InstallationID: "12345"}
+	_, err = cache.fetch(key, func() (*github.InstallationToken, error) {
+		return &github.InstallationToken{
+			Token:     github.String("ghs_cached"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}, nil
+	})
+	assert.NoError(t, err)
+
+	ctx := createTestContextForRevoke(map[string]interface{}{
+		"token":     "ghs_cached",
+		"silent":    true,
+		"cache":     true,
+		"cache-dir": cacheDir,
+	})
+	err = Revoke(ctx)
+	assert.NoError(t, err)
+
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	assert.Equal(t, 0, len(entries))
+}
@@ -0,0 +1,480 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultRefreshMargin is how long before their expiry served tokens are
+	// replaced, installation tokens being valid for an hour
+	defaultRefreshMargin = 10 * time.Minute
+
+	// refreshInterval is how often the broker looks for tokens to refresh,
+	// and retries failed refreshes
+	refreshInterval = 30 * time.Second
+
+	// shutdownTimeout bounds how long in-flight requests may take to
+	// complete once the server is asked to stop
+	shutdownTimeout = 10 * time.Second
+)
+
+var errInstallationNotServed = errors.New("installation is not served by this broker")
+
+// Serve is the entrypoint for the serve command
+func Serve(c *cli.Context) error {
+	installationIDs := c.StringSlice("installation-id")
+	listen := c.String("listen")
+	socket := c.String("socket")
+	refreshMargin := c.Duration("refresh-margin")
+	policyPath := c.String("policy")
+	listenTokenFile := c.String("listen-token-file")
+
+	if (listen == "") == (socket == "") {
+		return fmt.Errorf("exactly one of --listen or --socket must be specified")
+	}
+
+	if listen != "" && listenTokenFile == "" {
+		return fmt.Errorf("--listen requires --listen-token-file since every local user can connect to a TCP address")
+	}
+
+	if listenTokenFile != "" && listen == "" {
+		return fmt.Errorf("--listen-token-file requires --listen")
+	}
+
+	if policyPath != "" && socket == "" {
+		return fmt.Errorf("--policy requires --socket since clients are identified by their peer credentials")
+	}
+
+	if policyPath != "" && !peerCredentialsSupported {
+		return fmt.Errorf("--policy is not supported on this platform")
+	}
+
+	hostname, err := apiBaseURL(c.String("hostname"))
+	if err != nil {
+		return err
+	}
+
+	appID, err := requireAppID(c)
+	if err != nil {
+		return err
+	}
+
+	if len(installationIDs) == 0 {
+		return fmt.Errorf("at least one --installation-id must be specified")
+	}
+
+	keys, err := loadAppKeys(c)
+	if err != nil {
+		return err
+	}
+	defer keys.close()
+
+	// Fail at startup rather than on the first refresh
+	for _, key := range keys {
+		if _, err := key.get(); err != nil {
+			return err
+		}
+	}
+
+	broker, err := newTokenBroker(hostname, appID, keys, installationIDs, refreshMargin)
+	if err != nil {
+		return err
+	}
+
+	if policyPath != "" {
+		broker.policy, err = loadServePolicy(policyPath, installationIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if listenTokenFile != "" {
+		broker.secret, err = readListenToken(listenTokenFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var listener net.Listener
+	if socket != "" {
+		// With a policy other users must be able to connect, the policy
+		// deciding what they receive
+		mode := os.FileMode(0600)
+		if broker.policy != nil {
+			mode = 0666
+		}
+		listener, err = listenUnix(socket, mode)
+	} else {
+		listener, err = listenLoopback(listen)
+	}
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           broker.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext:       broker.connContext,
+	}
+
+	go broker.run(c.Context)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving installation tokens for %d installations on %s\n", len(installationIDs), listener.Addr())
+
+	select {
+	case err = <-serveErr:
+		return fmt.Errorf("failed serving installation tokens: %w", err)
+	case <-c.Context.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("failed shutting down gracefully: %w", err)
+	}
+
+	return nil
+}
+
+// listenUnix listens on a Unix socket with the given permissions, replacing a
+// socket left behind by a previous run
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	if err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("unable to listen on %s: the file exists and is not a socket", path)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %w", path, err)
+	}
+
+	err = os.Chmod(path, mode)
+	if err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("unable to restrict access to %s: %w", path, err)
+	}
+
+	return listener, nil
+}
+
+// minListenTokenLength is the minimum length of the secret clients of the
+// TCP listener authenticate with
+const minListenTokenLength = 16
+
+// listenLoopback listens on a TCP address, refusing addresses reachable from
+// other hosts since tokens are served to anyone holding the listen token
+func listenLoopback(address string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --listen address %q: %w", address, err)
+	}
+
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("refusing to serve tokens on %q, --listen only accepts loopback addresses such as 127.0.0.1:8080, use --socket or a reverse proxy to serve other hosts", address)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %w", address, err)
+	}
+
+	return listener, nil
+}
+
+// readListenToken reads the secret clients of the TCP listener must present
+// as a bearer token
+func readListenToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read --listen-token-file: %w", err)
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if len(secret) < minListenTokenLength {
+		return "", fmt.Errorf("the --listen-token-file must hold at least %d characters, example: openssl rand -hex 32", minListenTokenLength)
+	}
+
+	return secret, nil
+}
+
+// tokenGrant is a token kept by the broker, for an installation and
+// optionally restricted to repositories and permissions
+type tokenGrant struct {
+	InstallationID string
+	Options        *github.InstallationTokenOptions
+}
+
+// key identifies the grant among the tokens kept by the broker
+func (g tokenGrant) key() string {
+	if g.Options == nil {
+		return g.InstallationID
+	}
+
+	options, _ := json.Marshal(g.Options)
+	return g.InstallationID + " " + string(options)
+}
+
+// peerCredentialsKey is the context key of the credentials of the client
+// connected to the Unix socket
+type peerCredentialsKey struct{}
+
+// tokenBroker keeps installation tokens for a fixed set of installations,
+// refreshing them ahead of their expiry so they can be handed out at once.
+// With a policy, clients only receive the tokens it grants them. With a
+// secret, clients must present it as a bearer token.
+type tokenBroker struct {
+	hostname      string
+	appID         string
+	keys          appKeys
+	margin        time.Duration
+	installations []string
+	policy        *servePolicy
+	secret        string
+
+	mu     sync.Mutex
+	tokens map[string]*github.InstallationToken
+	group  singleflight.Group
+}
+
+func newTokenBroker(hostname, appID string, keys appKeys, installations []string, margin time.Duration) (*tokenBroker, error) {
+	if len(installations) == 0 {
+		return nil, fmt.Errorf("at least one --installation-id must be specified")
+	}
+
+	if margin <= 0 || margin >= time.Hour {
+		margin = defaultRefreshMargin
+	}
+
+	return &tokenBroker{
+		hostname:      hostname,
+		appID:         appID,
+		keys:          keys,
+		margin:        margin,
+		installations: installations,
+		tokens:        make(map[string]*github.InstallationToken),
+	}, nil
+}
+
+// connContext records the credentials of clients connecting to the Unix
+// socket when a policy is enforced
+func (b *tokenBroker) connContext(ctx context.Context, conn net.Conn) context.Context {
+	if b.policy == nil {
+		return ctx
+	}
+
+	peer, err := readPeerCredentials(conn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ctx
+	}
+
+	return context.WithValue(ctx, peerCredentialsKey{}, peer)
+}
+
+// authorized reports whether the request carries the secret of the broker,
+// when it has one
+func (b *tokenBroker) authorized(r *http.Request) bool {
+	if b.secret == "" {
+		return true
+	}
+
+	bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(bearer), []byte(b.secret)) == 1
+}
+
+// grant returns the token the client of the request may receive for the
+// installation
+func (b *tokenBroker) grant(ctx context.Context, installationID string) (tokenGrant, error) {
+	if !slices.Contains(b.installations, installationID) {
+		return tokenGrant{}, errInstallationNotServed
+	}
+
+	if b.policy == nil {
+		return tokenGrant{InstallationID: installationID}, nil
+	}
+
+	peer, ok := ctx.Value(peerCredentialsKey{}).(*peerCredentials)
+	if !ok {
+		return tokenGrant{}, fmt.Errorf("unable to identify the client: %w", errPeerNotAuthorized)
+	}
+
+	return b.policy.grant(peer, installationID)
+}
+
+// grants returns the tokens kept fresh in the background
+func (b *tokenBroker) grants() []tokenGrant {
+	if b.policy != nil {
+		return b.policy.grants()
+	}
+
+	grants := make([]tokenGrant, 0, len(b.installations))
+	for _, installationID := range b.installations {
+		grants = append(grants, tokenGrant{InstallationID: installationID})
+	}
+
+	return grants
+}
+
+// token returns the token for the grant, only reaching out to the API when
+// the current one is missing or about to expire
+func (b *tokenBroker) token(grant tokenGrant) (*github.InstallationToken, error) {
+	b.mu.Lock()
+	token := b.tokens[grant.key()]
+	b.mu.Unlock()
+
+	if b.fresh(token) {
+		return token, nil
+	}
+
+	return b.refresh(grant)
+}
+
+// fresh reports whether the token remains valid for longer than the
+// refresh margin
+func (b *tokenBroker) fresh(token *github.InstallationToken) bool {
+	if token == nil || token.ExpiresAt == nil {
+		return false
+	}
+
+	return time.Until(token.ExpiresAt.Time) > b.margin
+}
+
+// refresh generates a new token for the grant. Concurrent refreshes of the
+// same grant share a single API call.
+func (b *tokenBroker) refresh(grant tokenGrant) (*github.InstallationToken, error) {
+	key := grant.key()
+	result, err, _ := b.group.Do(key, func() (interface{}, error) {
+		var token *github.InstallationToken
+		err := b.keys.withJWT(b.appID, 10, func(jsonWebToken string) error {
+			var err error
+			// The refresh is shared by the waiting requests, so none of them
+			// may cancel it, the client timeout bounds it instead
+			token, err = generateToken(context.Background(), b.hostname, jsonWebToken, grant.InstallationID, grant.Options)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed generating installation token: %w", err)
+		}
+
+		b.mu.Lock()
+		b.tokens[key] = token
+		b.mu.Unlock()
+
+		return token, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*github.InstallationToken), nil
+}
+
+// refreshStale refreshes the tokens that are missing or about to expire
+func (b *tokenBroker) refreshStale() {
+	for _, grant := range b.grants() {
+		b.mu.Lock()
+		token := b.tokens[grant.key()]
+		b.mu.Unlock()
+
+		if b.fresh(token) {
+			continue
+		}
+
+		_, err := b.refresh(grant)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: installation %s: %v\n", grant.InstallationID, err)
+		}
+	}
+}
+
+// run keeps the tokens fresh in the background until the context is done
+func (b *tokenBroker) run(ctx context.Context) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		b.refreshStale()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *tokenBroker) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /installations/{id}/token", func(w http.ResponseWriter, r *http.Request) {
+		if !b.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "missing or invalid bearer token"})
+			return
+		}
+
+		grant, err := b.grant(r.Context(), r.PathValue("id"))
+		if err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, errPeerNotAuthorized) {
+				status = http.StatusForbidden
+			}
+			writeJSON(w, status, map[string]string{"message": err.Error()})
+			return
+		}
+
+		token, err := b.token(grant)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"message": err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, token)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
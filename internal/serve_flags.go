@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ServeFlags returns the CLI flags for the serve command
+func ServeFlags() []cli.Flag {
+	return slices.Concat([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "app-id",
+			Usage:    "GitHub App ID",
+			Required: false,
+			Aliases:  []string{"i", "app_id"},
+		},
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "Name of the configuration file profile providing the defaults of the other flags",
+			Required: false,
+			EnvVars:  []string{"GH_TOKEN_PROFILE"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "installation-id",
+			Usage:    "GitHub App installation ID to serve tokens for, can be repeated",
+			Required: false,
+			Aliases:  []string{"l", "installation_id"},
+		},
+		&cli.StringFlag{
+			Name:     "hostname",
+			Usage:    "GitHub Enterprise Server or GHE.com host, or API URL, example: github.example.com, octocorp.ghe.com or http://localhost:8080/api/v3",
+			Required: false,
+			Aliases:  []string{"o"},
+			Value:    "api.github.com",
+		},
+		&cli.StringFlag{
+			Name:     "listen",
+			Usage:    "Loopback TCP address to serve tokens on, requires --listen-token-file, example: 127.0.0.1:8080",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "listen-token-file",
+			Usage:    "Path to a file holding the secret clients of --listen must send as a bearer token",
+			Required: false,
+			Aliases:  []string{"listen_token_file"},
+		},
+		&cli.StringFlag{
+			Name:     "socket",
+			Usage:    "Path of a Unix socket to serve tokens on, only accessible to the current user",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "policy",
+			Usage:    "Path to a YAML policy mapping the UIDs and GIDs of clients connecting to --socket to the installations and permissions they may receive (Linux only)",
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "refresh-margin",
+			Usage:    "How long before their expiry tokens are refreshed in the background",
+			Required: false,
+			Aliases:  []string{"refresh_margin"},
+			Value:    defaultRefreshMargin,
+		},
+	}, keyFlags(), httpFlags())
+}
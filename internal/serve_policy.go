@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+
+	"github.com/google/go-github/v55/github"
+	"gopkg.in/yaml.v3"
+)
+
+var errPeerNotAuthorized = errors.New("not authorized")
+
+// servePolicy decides which local users may receive tokens from the broker.
+// Rules are evaluated in order and the first one matching both the client
+// and the requested installation applies.
+type servePolicy struct {
+	Rules []servePolicyRule `yaml:"rules"`
+}
+
+// servePolicyRule grants the users and groups it lists tokens for its
+// installations, restricted to its repositories and permissions
+type servePolicyRule struct {
+	Name          string   `yaml:"name"`
+	UIDs          []uint32 `yaml:"uids"`
+	GIDs          []uint32 `yaml:"gids"`
+	Users         []string `yaml:"users"`
+	Groups        []string `yaml:"groups"`
+	Installations []string `yaml:"installations"`
+	Repositories  []string `yaml:"repositories"`
+	Permissions   []string `yaml:"permissions"`
+
+	options *github.InstallationTokenOptions
+}
+
+// peerCredentials identifies the process at the other end of a Unix socket
+type peerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// loadServePolicy reads and validates a policy file. The installations of
+// every rule must be among the installations served by the broker.
+func loadServePolicy(path string, installations []string) (*servePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy file: %w", err)
+	}
+
+	policy := &servePolicy{}
+	err = yaml.Unmarshal(data, policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse policy file: %w", err)
+	}
+
+	if len(policy.Rules) == 0 {
+		return nil, fmt.Errorf("the policy file %s does not define any rules", path)
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+
+		err = rule.resolve(installations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy rule %s: %w", rule.Name, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// resolve validates the rule, translating user and group names to IDs and
+// the scope to the options of the token request
+func (r *servePolicyRule) resolve(installations []string) error {
+	for _, name := range r.Users {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return fmt.Errorf("unable to look up user %q: %w", name, err)
+		}
+
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("unsupported UID %q for user %q", u.Uid, name)
+		}
+		r.UIDs = append(r.UIDs, uint32(uid))
+	}
+
+	for _, name := range r.Groups {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return fmt.Errorf("unable to look up group %q: %w", name, err)
+		}
+
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("unsupported GID %q for group %q", g.Gid, name)
+		}
+		r.GIDs = append(r.GIDs, uint32(gid))
+	}
+
+	if len(r.UIDs) == 0 && len(r.GIDs) == 0 {
+		return fmt.Errorf("at least one of uids, gids, users or groups must be specified")
+	}
+
+	if len(r.Installations) == 0 {
+		return fmt.Errorf("at least one installation must be specified")
+	}
+
+	for _, installationID := range r.Installations {
+		if !slices.Contains(installations, installationID) {
+			return fmt.Errorf("installation %s is not served, add it with --installation-id", installationID)
+		}
+	}
+
+	options, err := installationTokenOptions(r.Repositories, nil, r.Permissions)
+	if err != nil {
+		return err
+	}
+	r.options = options
+
+	return nil
+}
+
+// matches reports whether the rule applies to the client
+func (r *servePolicyRule) matches(peer *peerCredentials) bool {
+	return slices.Contains(r.UIDs, peer.UID) || slices.Contains(r.GIDs, peer.GID)
+}
+
+// grant returns the token the client may receive for the installation
+func (p *servePolicy) grant(peer *peerCredentials, installationID string) (tokenGrant, error) {
+	for _, rule := range p.Rules {
+		if rule.matches(peer) && slices.Contains(rule.Installations, installationID) {
+			return tokenGrant{InstallationID: installationID, Options: rule.options}, nil
+		}
+	}
+
+	return tokenGrant{}, fmt.Errorf("uid %d gid %d is %w to receive tokens for installation %s",
+		peer.UID, peer.GID, errPeerNotAuthorized, installationID)
+}
+
+// grants returns every token the policy may hand out, so they can be kept
+// fresh in the background
+func (p *servePolicy) grants() []tokenGrant {
+	var grants []tokenGrant
+	seen := make(map[string]bool)
+	for _, rule := range p.Rules {
+		for _, installationID := range rule.Installations {
+			grant := tokenGrant{InstallationID: installationID, Options: rule.options}
+			if !seen[grant.key()] {
+				seen[grant.key()] = true
+				grants = append(grants, grant)
+			}
+		}
+	}
+
+	return grants
+}
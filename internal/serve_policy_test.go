@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeServePolicy writes a policy file with the given contents and returns
+// its path
+func writeServePolicy(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+
+	return path
+}
+
+const testServePolicy = `
+rules:
+  - name: team-a
+    uids: [1001]
+    installations: [12345]
+    repositories: [infra]
+    permissions: [contents:read]
+  - name: release
+    gids: [3000]
+    installations: ["12345", "67890"]
+`
+
+func TestLoadServePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		contents      string
+		expectedError string
+	}{
+		{
+			name:     "valid_policy",
+			contents: testServePolicy,
+		},
+		{
+			name:          "no_rules",
+			contents:      "rules: []\n",
+			expectedError: "does not define any rules",
+		},
+		{
+			name:          "invalid_yaml",
+			contents:      "rules: {",
+			expectedError: "unable to parse policy file",
+		},
+		{
+			name:          "rule_without_clients",
+			contents:      "rules:\n  - name: nobody\n    installations: [12345]\n",
+			expectedError: "invalid policy rule nobody: at least one of uids, gids, users or groups must be specified",
+		},
+		{
+			name:          "rule_without_installations",
+			contents:      "rules:\n  - uids: [1001]\n",
+			expectedError: "invalid policy rule #1: at least one installation must be specified",
+		},
+		{
+			name:          "installation_not_served",
+			contents:      "rules:\n  - uids: [1001]\n    installations: [99999]\n",
+			expectedError: "installation 99999 is not served, add it with --installation-id",
+		},
+		{
+			name:          "invalid_permission",
+			contents:      "rules:\n  - uids: [1001]\n    installations: [12345]\n    permissions: [contents:none]\n",
+			expectedError: "invalid access level \"none\" for permission \"contents\"",
+		},
+		{
+			name:          "unknown_user",
+			contents:      "rules:\n  - users: [gh-token-user-that-does-not-exist]\n    installations: [12345]\n",
+			expectedError: "unable to look up user \"gh-token-user-that-does-not-exist\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := loadServePolicy(writeServePolicy(t, tt.contents), []string{"12345", "67890"})
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, policy)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, policy.Rules, 2)
+		})
+	}
+}
+
+func TestServePolicyGrant(t *testing.T) {
+	policy, err := loadServePolicy(writeServePolicy(t, testServePolicy), []string{"12345", "67890"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		peer                 peerCredentials
+		installationID       string
+		expectedRepositories []string
+		expectedError        string
+	}{
+		{
+			name:                 "uid_receives_scoped_token",
+			peer:                 peerCredentials{UID: 1001, GID: 1001},
+			installationID:       "12345",
+			expectedRepositories: []string{"infra"},
+		},
+		{
+			name:           "first_matching_rule_applies",
+			peer:           peerCredentials{UID: 1001, GID: 3000},
+			installationID: "12345",
+			// The team-a rule comes first and restricts the token
+			expectedRepositories: []string{"infra"},
+		},
+		{
+			name:           "gid_receives_unscoped_token",
+			peer:           peerCredentials{UID: 1002, GID: 3000},
+			installationID: "67890",
+		},
+		{
+			name:           "installation_not_granted",
+			peer:           peerCredentials{UID: 1001, GID: 1001},
+			installationID: "67890",
+			expectedError:  "uid 1001 gid 1001 is not authorized to receive tokens for installation 67890",
+		},
+		{
+			name:           "unknown_client",
+			peer:           peerCredentials{UID: 1003, GID: 1003},
+			installationID: "12345",
+			expectedError:  "uid 1003 gid 1003 is not authorized to receive tokens for installation 12345",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grant, err := policy.grant(&tt.peer, tt.installationID)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.ErrorIs(t, err, errPeerNotAuthorized)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.installationID, grant.InstallationID)
+			if tt.expectedRepositories == nil {
+				assert.Nil(t, grant.Options)
+			} else {
+				assert.Equal(t, tt.expectedRepositories, grant.Options.Repositories)
+			}
+		})
+	}
+
+	// Each distinct scope is kept fresh once
+	assert.Len(t, policy.grants(), 3)
+}
+
+func TestTokenBrokerHandlerWithPolicy(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var requestBody string
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			requestBody = string(body)
+			return httpmock.NewJsonResponse(201, &github.InstallationToken{
+				Token:     github.String("ghs_scoped"),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			})
+		})
+
+	broker := newTestTokenBroker(t, "12345", "67890")
+	policy, err := loadServePolicy(writeServePolicy(t, testServePolicy), broker.installations)
+	assert.NoError(t, err)
+	broker.policy = policy
+
+	tests := []struct {
+		name           string
+		peer           *peerCredentials
+		expectedStatus int
+	}{
+		{
+			name:           "authorized_client",
+			peer:           &peerCredentials{UID: 1001, GID: 1001},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unauthorized_client",
+			peer:           &peerCredentials{UID: 1003, GID: 1003},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "unidentified_client",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestBody = ""
+			req := httptest.NewRequest("GET", "/installations/12345/token", nil)
+			if tt.peer != nil {
+				req = req.WithContext(context.WithValue(req.Context(), peerCredentialsKey{}, tt.peer))
+			}
+
+			recorder := httptest.NewRecorder()
+			broker.handler().ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var options github.InstallationTokenOptions
+				assert.NoError(t, json.Unmarshal([]byte(requestBody), &options))
+				assert.Equal(t, []string{"infra"}, options.Repositories)
+				assert.Equal(t, "read", options.Permissions.GetContents())
+			} else {
+				assert.Empty(t, requestBody)
+			}
+		})
+	}
+}
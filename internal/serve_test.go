@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"crypto"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTokenBroker creates a broker serving the given installations with
+// the test private key
+func newTestTokenBroker(t *testing.T, installations ...string) *tokenBroker {
+	t.Helper()
+
+	key, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+
+	keys := appKeys{{name: "test", load: func() (crypto.Signer, error) { return key, nil }}}
+	broker, err := newTokenBroker("api.github.com", "123456", keys, installations, defaultRefreshMargin)
+	assert.NoError(t, err)
+
+	return broker
+}
+
+// registerTokenResponder answers token requests for the installation with
+// tokens expiring after the given duration and counts the calls
+func registerTokenResponder(installationID string, expiresIn time.Duration) {
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/"+installationID+"/access_tokens",
+		func(_ *http.Request) (*http.Response, error) {
+			// Keep the request in flight long enough for concurrent callers to overlap
+			time.Sleep(20 * time.Millisecond)
+			return httpmock.NewJsonResponse(201, &github.InstallationToken{
+				Token:     github.String("ghs_" + installationID),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(expiresIn)},
+			})
+		})
+}
+
+func TestTokenBrokerHandler(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tests := []struct {
+		name           string
+		path           string
+		tokenStatus    int
+		expectedStatus int
+		expectedToken  string
+		expectedError  string
+	}{
+		{
+			name:           "served_installation",
+			path:           "/installations/12345/token",
+			tokenStatus:    201,
+			expectedStatus: http.StatusOK,
+			expectedToken:  "ghs_12345",
+		},
+		{
+			name:           "installation_not_served",
+			path:           "/installations/99999/token",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "installation is not served by this broker",
+		},
+		{
+			name:           "token_generation_failure",
+			path:           "/installations/12345/token",
+			tokenStatus:    401,
+			expectedStatus: http.StatusBadGateway,
+			expectedError:  "failed generating installation token: unexpected status code: 401",
+		},
+		{
+			name:           "health_check",
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			if tt.tokenStatus == 201 {
+				registerTokenResponder("12345", time.Hour)
+			} else {
+				httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+					httpmock.NewStringResponder(tt.tokenStatus, `{"message": "Bad credentials"}`))
+			}
+
+			broker := newTestTokenBroker(t, "12345")
+			recorder := httptest.NewRecorder()
+			broker.handler().ServeHTTP(recorder, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			if tt.expectedToken != "" {
+				assert.Equal(t, tt.expectedToken, body["token"])
+			}
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, body["message"])
+			}
+		})
+	}
+}
+
+func TestTokenBrokerHandlerRequiresSecret(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	registerTokenResponder("12345", time.Hour)
+	broker := newTestTokenBroker(t, "12345")
+	broker.secret = "0123456789abcdef"
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "missing_secret",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong_secret",
+			authorization:  "Bearer fedcba9876543210",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "valid_secret",
+			authorization:  "Bearer 0123456789abcdef",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/installations/12345/token", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			broker.handler().ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Contains(t, recorder.Body.String(), "ghs_12345")
+			} else {
+				assert.NotContains(t, recorder.Body.String(), "ghs_12345")
+			}
+		})
+	}
+}
+
+func TestListenLoopback(t *testing.T) {
+	tests := []struct {
+		name          string
+		address       string
+		expectedError string
+	}{
+		{
+			name:    "ipv4_loopback",
+			address: "127.0.0.1:0",
+		},
+		{
+			name:    "localhost",
+			address: "localhost:0",
+		},
+		{
+			name:          "all_interfaces",
+			address:       ":8080",
+			expectedError: "only accepts loopback addresses",
+		},
+		{
+			name:          "unspecified_address",
+			address:       "0.0.0.0:8080",
+			expectedError: "only accepts loopback addresses",
+		},
+		{
+			name:          "other_host",
+			address:       "broker.example.com:8080",
+			expectedError: "only accepts loopback addresses",
+		},
+		{
+			name:          "missing_port",
+			address:       "127.0.0.1",
+			expectedError: "invalid --listen address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := listenLoopback(tt.address)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			_ = listener.Close()
+		})
+	}
+}
+
+func TestReadListenToken(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(path, []byte("0123456789abcdef\n"), 0600))
+	secret, err := readListenToken(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", secret)
+
+	short := filepath.Join(dir, "short")
+	assert.NoError(t, os.WriteFile(short, []byte("secret"), 0600))
+	_, err = readListenToken(short)
+	assert.ErrorContains(t, err, "must hold at least 16 characters")
+}
+
+func TestTokenBrokerReusesFreshTokens(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	registerTokenResponder("12345", time.Hour)
+	broker := newTestTokenBroker(t, "12345")
+
+	for i := 0; i < 3; i++ {
+		token, err := broker.token(tokenGrant{InstallationID: "12345"})
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_12345", token.GetToken())
+	}
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, info["POST https://api.github.com/app/installations/12345/access_tokens"])
+}
+
+func TestTokenBrokerSharesConcurrentRefreshes(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	registerTokenResponder("12345", time.Hour)
+	broker := newTestTokenBroker(t, "12345")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := broker.token(tokenGrant{InstallationID: "12345"})
+			assert.NoError(t, err)
+			assert.Equal(t, "ghs_12345", token.GetToken())
+		}()
+	}
+	wg.Wait()
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, info["POST https://api.github.com/app/installations/12345/access_tokens"])
+}
+
+func TestTokenBrokerRefreshesBeforeExpiry(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	// Tokens expiring within the refresh margin are replaced on every pass
+	registerTokenResponder("12345", 5*time.Minute)
+	registerTokenResponder("67890", time.Hour)
+	broker := newTestTokenBroker(t, "12345", "67890")
+
+	broker.refreshStale()
+	broker.refreshStale()
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 2, info["POST https://api.github.com/app/installations/12345/access_tokens"])
+	assert.Equal(t, 1, info["POST https://api.github.com/app/installations/67890/access_tokens"])
+}
+
+func TestNewTokenBrokerRequiresInstallations(t *testing.T) {
+	broker, err := newTokenBroker("api.github.com", "123456", nil, nil, defaultRefreshMargin)
+
+	assert.Nil(t, broker)
+	assert.EqualError(t, err, "at least one --installation-id must be specified")
+}
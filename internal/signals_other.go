@@ -0,0 +1,45 @@
+//go:build !unix
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// forwardedSignals are relayed by gh-token to the processes it runs
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// terminalSignals are delivered by the console to every process attached to
+// it, including the processes gh-token runs
+var terminalSignals = []os.Signal{os.Interrupt}
+
+// parseSignal returns the signal with the given name, only SIGKILL being
+// supported on this platform
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if name != "KILL" {
+		return nil, fmt.Errorf("unsupported signal %q, only KILL is supported on this platform", name)
+	}
+
+	return os.Kill, nil
+}
+
+// inForegroundProcessGroup reports whether the processes run by gh-token
+// receive the terminalSignals typed on the console along with it, which is
+// always the case for console applications on this platform
+func inForegroundProcessGroup() bool {
+	return true
+}
+
+// exitCode returns the exit code of a process, 1 when it did not exit
+// normally
+func exitCode(state *os.ProcessState) int {
+	code := state.ExitCode()
+	if code < 0 {
+		return 1
+	}
+
+	return code
+}
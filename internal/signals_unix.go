@@ -0,0 +1,74 @@
+//go:build unix
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// forwardedSignals are relayed by gh-token to the processes it runs
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+}
+
+// terminalSignals are sent by the terminal to its whole foreground process
+// group, which already includes the processes gh-token runs
+var terminalSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT}
+
+// parseSignal returns the signal with the given name, with or without the
+// SIG prefix
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return nil, fmt.Errorf("unknown signal %q", name)
+	}
+
+	return sig, nil
+}
+
+// inForegroundProcessGroup reports whether gh-token belongs to the foreground
+// process group of its controlling terminal, in which case the processes it
+// runs receive the terminalSignals typed on the terminal along with it
+func inForegroundProcessGroup() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = tty.Close()
+	}()
+
+	pgrp, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+
+	return pgrp == unix.Getpgrp()
+}
+
+// exitCode returns the exit code of a process, following the shell
+// convention of 128 plus the signal number when it was killed by a signal
+func exitCode(state *os.ProcessState) int {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/urfave/cli/v2"
+)
+
+// Watch is the entrypoint for the watch command
+func Watch(c *cli.Context) error {
+	refreshMargin := c.Duration("refresh-margin")
+
+	if c.String("token-file") == "" {
+		return fmt.Errorf("--token-file must be specified")
+	}
+
+	if c.Bool("cache") {
+		return fmt.Errorf("--cache cannot be combined with watch since tokens are already refreshed ahead of their expiry")
+	}
+
+	if refreshMargin <= 0 || refreshMargin >= time.Hour {
+		refreshMargin = defaultRefreshMargin
+	}
+
+	_, err := parseFileMode(c.String("file-mode"))
+	if err != nil {
+		return err
+	}
+
+	if c.Int("signal-pid") > 0 {
+		_, err = parseSignal(c.String("signal"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The keys are only read once, as stdin and file descriptors cannot be
+	// read again and encrypted keys would prompt for their passphrase again
+	keys, err := loadAppKeys(c)
+	if err != nil {
+		return err
+	}
+	defer keys.close()
+
+	written := false
+	for {
+		wait := refreshInterval
+		token, err := rotateToken(c.Context, c, keys)
+		if err != nil {
+			// Fail at once on configuration errors rather than retrying
+			// forever without ever writing the token file
+			if !written && token == nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		// A failed reload still leaves a valid token in place
+		if token != nil {
+			written = true
+			if token.ExpiresAt != nil {
+				wait = max(time.Until(token.ExpiresAt.Time)-refreshMargin, refreshInterval)
+			}
+		}
+
+		select {
+		case <-c.Context.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// rotateToken generates a new token signed with keys, atomically replaces
+// the token and metadata files with it and notifies the consumers of the
+// files
+func rotateToken(ctx context.Context, c *cli.Context, keys appKeys) (*github.InstallationToken, error) {
+	tokenFile := c.String("token-file")
+	metadataFile := c.String("metadata-file")
+	signalPID := c.Int("signal-pid")
+	reloadCommand := c.Args().Slice()
+
+	mode, err := parseFileMode(c.String("file-mode"))
+	if err != nil {
+		return nil, err
+	}
+
+	token, _, err := issueToken(ctx, c, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// The metadata file is written first so consumers reading it after the
+	// token file changed never see the previous expiry
+	if metadataFile != "" {
+		metadata := *token
+		metadata.Token = nil
+
+		bytes, err := json.MarshalIndent(metadata, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal token metadata to JSON: %w", err)
+		}
+
+		err = writeFileAtomic(metadataFile, append(bytes, '\n'), mode)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write metadata file: %w", err)
+		}
+	}
+
+	err = writeFileAtomic(tokenFile, []byte(token.GetToken()), mode)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write token file: %w", err)
+	}
+
+	if len(reloadCommand) > 0 {
+		cmd := exec.Command(reloadCommand[0], reloadCommand[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err != nil {
+			return token, fmt.Errorf("reload command %s failed: %w", reloadCommand[0], err)
+		}
+	}
+
+	if signalPID > 0 {
+		sig, err := parseSignal(c.String("signal"))
+		if err != nil {
+			return token, err
+		}
+
+		process, err := os.FindProcess(signalPID)
+		if err == nil {
+			err = process.Signal(sig)
+		}
+		if err != nil {
+			return token, fmt.Errorf("unable to signal process %d: %w", signalPID, err)
+		}
+	}
+
+	return token, nil
+}
+
+// parseFileMode parses the octal permissions of the written files
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q, expected octal permissions such as 0600", value)
+	}
+
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,47 @@
+package internal
+
+import "github.com/urfave/cli/v2"
+
+// WatchFlags returns the CLI flags for the watch command
+func WatchFlags() []cli.Flag {
+	return append(tokenFlags(),
+		&cli.StringFlag{
+			Name:     "token-file",
+			Usage:    "Path of the file the token is written to",
+			Required: true,
+			Aliases:  []string{"token_file"},
+		},
+		&cli.StringFlag{
+			Name:     "metadata-file",
+			Usage:    "Path of a JSON file the expiry, permissions and repositories of the token are written to",
+			Required: false,
+			Aliases:  []string{"metadata_file"},
+		},
+		&cli.StringFlag{
+			Name:     "file-mode",
+			Usage:    "Octal permissions of the token and metadata files",
+			Required: false,
+			Aliases:  []string{"file_mode"},
+			Value:    "0600",
+		},
+		&cli.DurationFlag{
+			Name:     "refresh-margin",
+			Usage:    "How long before its expiry the token is replaced",
+			Required: false,
+			Aliases:  []string{"refresh_margin"},
+			Value:    defaultRefreshMargin,
+		},
+		&cli.IntFlag{
+			Name:     "signal-pid",
+			Usage:    "PID of a process to signal after each rotation",
+			Required: false,
+			Aliases:  []string{"signal_pid"},
+		},
+		&cli.StringFlag{
+			Name:     "signal",
+			Usage:    "Signal sent to --signal-pid after each rotation",
+			Required: false,
+			Value:    "HUP",
+		},
+	)
+}
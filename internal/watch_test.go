@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands require a POSIX shell")
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	tokenResponse := &github.InstallationToken{
+		Token:     github.String("ghs_test_token_123"),
+		ExpiresAt: &github.Timestamp{Time: expiresAt},
+		Permissions: &github.InstallationPermissions{
+			Contents: github.String("read"),
+		},
+	}
+	tokenJSON, _ := json.Marshal(tokenResponse)
+
+	tests := []struct {
+		name          string
+		flags         map[string]interface{}
+		args          []string
+		tokenStatus   int
+		expectedMode  os.FileMode
+		expectedError string
+	}{
+		{
+			name:         "token_file",
+			tokenStatus:  201,
+			expectedMode: 0600,
+		},
+		{
+			name: "token_and_metadata_files",
+			flags: map[string]interface{}{
+				"metadata-file": "metadata.json",
+				"file-mode":     "0640",
+			},
+			tokenStatus:  201,
+			expectedMode: 0640,
+		},
+		{
+			name:         "reload_command",
+			args:         []string{"sh", "-c", `test "$(cat "$0")" = ghs_test_token_123 && touch "$0.reloaded"`, "TOKEN_FILE"},
+			tokenStatus:  201,
+			expectedMode: 0600,
+		},
+		{
+			name:          "reload_command_failure",
+			args:          []string{"sh", "-c", "exit 1"},
+			tokenStatus:   201,
+			expectedMode:  0600,
+			expectedError: "reload command sh failed: exit status 1",
+		},
+		{
+			name:          "token_generation_failure",
+			tokenStatus:   401,
+			expectedError: "failed generating installation token: unexpected status code: 401",
+		},
+		{
+			name: "invalid_file_mode",
+			flags: map[string]interface{}{
+				"file-mode": "rw-------",
+			},
+			expectedError: "invalid file mode \"rw-------\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.Reset()
+			httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+				httpmock.NewStringResponder(tt.tokenStatus, string(tokenJSON)))
+
+			dir := t.TempDir()
+			tokenFile := filepath.Join(dir, "token")
+			flags := map[string]interface{}{
+				"token-file": tokenFile,
+				"file-mode":  "0600",
+				"signal":     "HUP",
+			}
+			for k, v := range tt.flags {
+				flags[k] = v
+			}
+			if name, ok := flags["metadata-file"].(string); ok {
+				flags["metadata-file"] = filepath.Join(dir, name)
+			}
+			var args []string
+			for _, arg := range tt.args {
+				if arg == "TOKEN_FILE" {
+					arg = tokenFile
+				}
+				args = append(args, arg)
+			}
+
+			token, err := rotateToken(context.Background(), createTestContextForExec(flags, args), nil)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "ghs_test_token_123", token.GetToken())
+			}
+
+			if tt.expectedMode == 0 {
+				assert.NoFileExists(t, tokenFile)
+				return
+			}
+
+			data, err := os.ReadFile(tokenFile)
+			assert.NoError(t, err)
+			assert.Equal(t, "ghs_test_token_123", string(data))
+
+			info, err := os.Stat(tokenFile)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMode, info.Mode().Perm())
+
+			if metadataFile, ok := flags["metadata-file"].(string); ok {
+				data, err := os.ReadFile(metadataFile)
+				assert.NoError(t, err)
+				assert.NotContains(t, string(data), "ghs_test_token_123")
+
+				var metadata github.InstallationToken
+				assert.NoError(t, json.Unmarshal(data, &metadata))
+				assert.Equal(t, expiresAt, metadata.GetExpiresAt().Time.UTC())
+				assert.Equal(t, "read", metadata.GetPermissions().GetContents())
+			}
+
+			if tt.name == "reload_command" {
+				assert.FileExists(t, tokenFile+".reloaded")
+			}
+		})
+	}
+}
+
+func TestWatchValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		flags         map[string]interface{}
+		expectedError string
+	}{
+		{
+			name: "cache",
+			flags: map[string]interface{}{
+				"cache": true,
+			},
+			expectedError: "--cache cannot be combined with watch",
+		},
+		{
+			name: "invalid_file_mode",
+			flags: map[string]interface{}{
+				"file-mode": "1777",
+			},
+			expectedError: "invalid file mode \"1777\"",
+		},
+		{
+			name: "unknown_signal",
+			flags: map[string]interface{}{
+				"signal-pid": 1,
+				"signal":     "SIGNOPE",
+			},
+			expectedError: "SIGNOPE",
+		},
+		{
+			name: "first_rotation_failure",
+			flags: map[string]interface{}{
+				"key": []string{"missing.pem"},
+			},
+			expectedError: "unable to read key file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := map[string]interface{}{
+				"token-file": filepath.Join(t.TempDir(), "token"),
+				"file-mode":  "0600",
+			}
+			for k, v := range tt.flags {
+				flags[k] = v
+			}
+
+			err := Watch(createTestContextForExec(flags, nil))
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestRotateTokenAsksPassphraseOnce(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		httpmock.NewJsonResponderOrPanic(201, &github.InstallationToken{
+			Token:     github.String("ghs_test_token_123"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}))
+
+	signer, err := readKey("fixtures/test-private-key.test.pem", nil)
+	assert.NoError(t, err)
+	//nolint:staticcheck // legacy encryption is what openssl rsa -aes256 writes
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(signer.(*rsa.PrivateKey)), []byte("correct horse"), x509.PEMCipherAES256)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+
+	prompts := 0
+	keys, err := keySources{
+		paths: []string{keyFile},
+		passphrase: func() ([]byte, error) {
+			prompts++
+			return []byte("correct horse"), nil
+		},
+	}.appKeys()
+	assert.NoError(t, err)
+	defer keys.close()
+
+	c := createTestContextForExec(map[string]interface{}{
+		"token-file": filepath.Join(dir, "token"),
+		"file-mode":  "0600",
+	}, nil)
+	for i := 0; i < 2; i++ {
+		_, err := rotateToken(context.Background(), c, keys)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, prompts)
+}
@@ -0,0 +1,86 @@
+//go:build unix
+
+package internal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateTokenSignalsProcess(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		httpmock.NewJsonResponderOrPanic(201, &github.InstallationToken{
+			Token:     github.String("ghs_test_token_123"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+
+	_, err := rotateToken(context.Background(), createTestContextForExec(map[string]interface{}{
+		"token-file": filepath.Join(t.TempDir(), "token"),
+		"file-mode":  "0600",
+		"signal-pid": os.Getpid(),
+		"signal":     "usr1",
+	}, nil), nil)
+	assert.NoError(t, err)
+
+	select {
+	case sig := <-signals:
+		assert.Equal(t, syscall.SIGUSR1, sig)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the process to receive SIGUSR1")
+	}
+}
+
+func TestRotateTokenReadsKeyOnce(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.github.com/app/installations/12345/access_tokens",
+		httpmock.NewJsonResponderOrPanic(201, &github.InstallationToken{
+			Token:     github.String("ghs_test_token_123"),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		}))
+
+	file, err := os.Open("fixtures/test-private-key.test.pem")
+	assert.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	// The key source closes the descriptor it reads, so hand it a copy
+	fd, err := syscall.Dup(int(file.Fd()))
+	assert.NoError(t, err)
+
+	c := createTestContextForExec(map[string]interface{}{
+		"key":        []string{"fd://" + strconv.Itoa(fd)},
+		"token-file": filepath.Join(t.TempDir(), "token"),
+		"file-mode":  "0600",
+	}, nil)
+	keys, err := loadAppKeys(c)
+	assert.NoError(t, err)
+	defer keys.close()
+
+	for i := 0; i < 2; i++ {
+		token, err := rotateToken(context.Background(), c, keys)
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_test_token_123", token.GetToken())
+	}
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 2, info["POST https://api.github.com/app/installations/12345/access_tokens"])
+}
@@ -2,8 +2,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/Link-/gh-token/internal"
 	"github.com/urfave/cli/v2"
@@ -21,26 +24,130 @@ func main() {
 				Name:   "generate",
 				Usage:  "Generate a new GitHub App installation token",
 				Flags:  internal.GenerateFlags(),
+				Before: before,
 				Action: internal.Generate,
 			},
+			{
+				Name:      "exec",
+				Usage:     "Run a command with a GitHub App installation token in its environment",
+				ArgsUsage: "-- command [arguments...]",
+				Flags:     internal.ExecFlags(),
+				Before:    before,
+				Action:    internal.Exec,
+			},
+			{
+				Name:      "credential",
+				Usage:     "Act as a git credential helper providing GitHub App installation tokens",
+				ArgsUsage: "get|store|erase",
+				Flags:     internal.CredentialFlags(),
+				Before:    before,
+				Action:    internal.Credential,
+			},
+			{
+				Name:      "watch",
+				Usage:     "Keep a GitHub App installation token file up to date",
+				ArgsUsage: "[-- reload-command [arguments...]]",
+				Flags:     internal.WatchFlags(),
+				Before:    before,
+				Action:    internal.Watch,
+			},
+			{
+				Name:   "serve",
+				Usage:  "Serve GitHub App installation tokens kept fresh in the background",
+				Flags:  internal.ServeFlags(),
+				Before: before,
+				Action: internal.Serve,
+			},
+			{
+				Name:  "key",
+				Usage: "Inspect GitHub App private keys",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "inspect",
+						Usage:  "Print the fingerprint of private keys and verify that they belong to an app",
+						Flags:  internal.KeyInspectFlags(),
+						Before: before,
+						Action: internal.KeyInspect,
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Manage the profiles of the configuration file",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List the profiles",
+						Action: internal.ConfigList,
+					},
+					{
+						Name:      "show",
+						Usage:     "Print the settings of a profile, or of the default profile",
+						ArgsUsage: "[profile]",
+						Action:    internal.ConfigShow,
+					},
+					{
+						Name:      "set",
+						Usage:     "Set a setting of a profile, creating the profile if needed",
+						ArgsUsage: "profile [setting value...]",
+						Flags:     internal.ConfigSetFlags(),
+						Action:    internal.ConfigSet,
+					},
+					{
+						Name:      "unset",
+						Usage:     "Remove a setting of a profile, or the whole profile",
+						ArgsUsage: "profile [setting]",
+						Action:    internal.ConfigUnset,
+					},
+					{
+						Name:      "validate",
+						Usage:     "Check the settings of the profiles and load their keys",
+						ArgsUsage: "[profile...]",
+						Flags:     internal.ConfigValidateFlags(),
+						Action:    internal.ConfigValidate,
+					},
+				},
+			},
 			{
 				Name:   "revoke",
 				Usage:  "Revoke a GitHub App installation token",
 				Flags:  internal.RevokeFlags(),
+				Before: before,
 				Action: internal.Revoke,
 			},
 			{
 				Name:   "installations",
 				Usage:  "List GitHub App installations",
 				Flags:  internal.InstallationsFlags(),
+				Before: before,
 				Action: internal.Installations,
 			},
 		},
 	}
 
-	err := app.Run(os.Args)
+	// Interrupting a command cancels its pending requests, and a second
+	// interrupt terminates it at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// before prepares the commands sending API requests, applying the selected
+// profile before configuring the HTTP client from the resulting flags
+func before(c *cli.Context) error {
+	err := internal.ApplyProfile(c)
+	if err != nil {
+		return err
+	}
+
+	return internal.ConfigureHTTPClient(c)
+}